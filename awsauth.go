@@ -3,6 +3,8 @@
 package awsauth
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"time"
@@ -19,41 +21,79 @@ type Credentials struct {
 
 // Sign signs a request bound for AWS. It automatically chooses the best
 // authentication scheme based on the service the request is going to.
+// It returns nil if the service is not known to the library.
 func Sign(request *http.Request, credentials ...Credentials) *http.Request {
-	service, _ := serviceAndRegion(request.URL.Host)
-	signVersion := awsSignVersion[service]
+	keys, _ := chooseKeys(credentials)
+	signed, _ := defaultSigner.sign(request, keys)
+	return signed
+}
+
+// SignE is like Sign, but reports an error instead of signing with empty
+// credentials, choosing no scheme for an unknown service or ignoring
+// a request body that can't be read.
+func SignE(request *http.Request, credentials ...Credentials) (*http.Request, error) {
+	keys, err := chooseKeys(credentials)
+	if err != nil {
+		return nil, err
+	}
+	return defaultSigner.sign(request, keys)
+}
+
+func (this *Signer) sign(request *http.Request, keys Credentials) (*http.Request, error) {
+	service, _ := this.serviceAndRegion(request, request.URL.Host)
 
-	switch signVersion {
+	switch this.signVersion(request, service) {
 	case 2:
-		return Sign2(request, credentials...)
+		return request, this.sign2(request, keys)
 	case 3:
-		return Sign3(request, credentials...)
+		this.sign3(request, keys)
+		return request, nil
 	case 4:
-		return Sign4(request, credentials...)
+		return request, this.sign4(request, keys)
 	case -1:
-		return SignS3(request, credentials...)
+		return request, this.signS3(request, keys)
 	}
 
-	return nil
+	return nil, fmt.Errorf("%w: %q", ErrUnknownService, service)
 }
 
 // Sign4 signs a request with Signed Signature Version 4.
 func Sign4(request *http.Request, credentials ...Credentials) *http.Request {
-	keys := chooseKeys(credentials)
+	keys, _ := chooseKeys(credentials)
+	defaultSigner.sign4(request, keys)
+	return request
+}
 
+// Sign4E is like Sign4, but reports missing credentials and unreadable
+// request bodies as errors.
+func Sign4E(request *http.Request, credentials ...Credentials) (*http.Request, error) {
+	keys, err := chooseKeys(credentials)
+	if err != nil {
+		return nil, err
+	}
+	if err := defaultSigner.sign4(request, keys); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func (this *Signer) sign4(request *http.Request, keys Credentials) error {
 	// Add the X-Amz-Security-Token header when using STS
 	if keys.SecurityToken != "" {
 		request.Header.Set("X-Amz-Security-Token", keys.SecurityToken)
 	}
 
-	prepareRequestV4(request)
+	this.prepareRequestV4(request)
 	meta := new(metadata)
 
 	// Task 1
-	hashedCanonReq := hashedCanonicalRequestV4(request, meta)
+	hashedCanonReq, err := this.hashedCanonicalRequestV4(request, meta)
+	if err != nil {
+		return err
+	}
 
 	// Task 2
-	stringToSign := stringToSignV4(request, hashedCanonReq, meta)
+	stringToSign := this.stringToSignV4(request, hashedCanonReq, meta)
 
 	// Task 3
 	signingKey := signingKeyV4(keys.SecretAccessKey, meta.date, meta.region, meta.service)
@@ -61,20 +101,130 @@ func Sign4(request *http.Request, credentials ...Credentials) *http.Request {
 
 	request.Header.Set("Authorization", buildAuthHeaderV4(signature, meta, keys))
 
+	return nil
+}
+
+// Sign4a signs a request with Signature Version 4a (AWS4-ECDSA-P256-SHA256),
+// which S3 Multi-Region Access Points and other endpoints that serve more than
+// one region require. The signature is made with an ECDSA key derived from the
+// secret access key and is valid in the regions of the X-Amz-Region-Set header,
+// which is set to the region of the request if it is missing.
+func Sign4a(request *http.Request, credentials ...Credentials) *http.Request {
+	keys, _ := chooseKeys(credentials)
+	defaultSigner.sign4a(request, keys)
 	return request
 }
 
+// Sign4aE is like Sign4a, but reports missing credentials and unreadable
+// request bodies as errors.
+func Sign4aE(request *http.Request, credentials ...Credentials) (*http.Request, error) {
+	keys, err := chooseKeys(credentials)
+	if err != nil {
+		return nil, err
+	}
+	if err := defaultSigner.sign4a(request, keys); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// SignS3Chunked signs an upload to S3 with Signed Signature Version 4 and
+// wraps its body so that it is sent in aws-chunked encoding, in chunks of
+// the given size (at least 8 KiB; see DefaultChunkSize) that each carry their
+// own signature. The body is never read ahead of what is being sent, so it may
+// be larger than would fit in memory. The request's ContentLength must be set
+// to the length of the body; it is replaced by the length of the encoded body.
+// A body of unknown length, including one whose ContentLength is left at zero,
+// can't be signed this way, and the request is left unsigned.
+func SignS3Chunked(request *http.Request, chunkSize int, credentials ...Credentials) *http.Request {
+	keys, _ := chooseKeys(credentials)
+	defaultSigner.signS3Chunked(request, chunkSize, keys)
+	return request
+}
+
+// SignS3ChunkedE is like SignS3Chunked, but reports missing credentials,
+// an unknown body length or an invalid chunk size as errors.
+func SignS3ChunkedE(request *http.Request, chunkSize int, credentials ...Credentials) (*http.Request, error) {
+	keys, err := chooseKeys(credentials)
+	if err != nil {
+		return nil, err
+	}
+	if err := defaultSigner.signS3Chunked(request, chunkSize, keys); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// Presign4 signs a request with Signed Signature Version 4 by adding the
+// credentials and signature to its query string instead of its headers, so
+// that the URL can be handed to someone else to perform the request before
+// it expires. Requests bound for S3 are signed with an unsigned payload,
+// which allows presigned uploads.
+func Presign4(request *http.Request, expires time.Duration, credentials ...Credentials) *http.Request {
+	keys, _ := chooseKeys(credentials)
+	defaultSigner.presignV4(request, expires, keys)
+	return request
+}
+
+// Presign4E is like Presign4, but reports missing credentials, unreadable
+// request bodies and an expiration outside of what AWS allows as errors.
+func Presign4E(request *http.Request, expires time.Duration, credentials ...Credentials) (*http.Request, error) {
+	keys, err := chooseKeys(credentials)
+	if err != nil {
+		return nil, err
+	}
+	if err := defaultSigner.presignV4(request, expires, keys); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// Presign4a is like Presign4, but signs with Signature Version 4a and adds
+// the region set to the query string as X-Amz-Region-Set.
+func Presign4a(request *http.Request, expires time.Duration, credentials ...Credentials) *http.Request {
+	keys, _ := chooseKeys(credentials)
+	defaultSigner.presignV4a(request, expires, keys)
+	return request
+}
+
+// Presign4aE is like Presign4a, but reports missing credentials, unreadable
+// request bodies and an expiration outside of what AWS allows as errors.
+func Presign4aE(request *http.Request, expires time.Duration, credentials ...Credentials) (*http.Request, error) {
+	keys, err := chooseKeys(credentials)
+	if err != nil {
+		return nil, err
+	}
+	if err := defaultSigner.presignV4a(request, expires, keys); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
 // Sign3 signs a request with Signed Signature Version 3.
 // If the service you're accessing supports Version 4, use that instead.
 func Sign3(request *http.Request, credentials ...Credentials) *http.Request {
-	keys := chooseKeys(credentials)
+	keys, _ := chooseKeys(credentials)
+	defaultSigner.sign3(request, keys)
+	return request
+}
+
+// Sign3E is like Sign3, but reports missing credentials as an error.
+func Sign3E(request *http.Request, credentials ...Credentials) (*http.Request, error) {
+	keys, err := chooseKeys(credentials)
+	if err != nil {
+		return nil, err
+	}
+	defaultSigner.sign3(request, keys)
+	return request, nil
+}
 
+func (this *Signer) sign3(request *http.Request, keys Credentials) {
 	// Add the X-Amz-Security-Token header when using STS
 	if keys.SecurityToken != "" {
 		request.Header.Set("X-Amz-Security-Token", keys.SecurityToken)
 	}
 
-	prepareRequestV3(request)
+	this.prepareRequestV3(request)
 
 	// Task 1
 	stringToSign := stringToSignV3(request)
@@ -84,14 +234,37 @@ func Sign3(request *http.Request, credentials ...Credentials) *http.Request {
 
 	// Task 3
 	request.Header.Set("X-Amzn-Authorization", buildAuthHeaderV3(signature, keys))
-
-	return request
 }
 
 // Sign2 signs a request with Signed Signature Version 2.
 // If the service you're accessing supports Version 4, use that instead.
+// The parameters of a POST request with an application/x-www-form-urlencoded
+// body are signed in the body, along with any in its query string, which are
+// moved there. The signature goes into the body as well, which is encoded
+// anew, with its ContentLength updated to match.
 func Sign2(request *http.Request, credentials ...Credentials) *http.Request {
-	keys := chooseKeys(credentials)
+	keys, _ := chooseKeys(credentials)
+	defaultSigner.sign2(request, keys)
+	return request
+}
+
+// Sign2E is like Sign2, but reports missing credentials and unreadable
+// request bodies as errors.
+func Sign2E(request *http.Request, credentials ...Credentials) (*http.Request, error) {
+	keys, err := chooseKeys(credentials)
+	if err != nil {
+		return nil, err
+	}
+	if err := defaultSigner.sign2(request, keys); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func (this *Signer) sign2(request *http.Request, keys Credentials) error {
+	if isFormV2(request) {
+		return this.signFormV2(request, keys)
+	}
 
 	// Add the SecurityToken parameter when using STS
 	// This must be added before the signature is calculated
@@ -101,7 +274,7 @@ func Sign2(request *http.Request, credentials ...Credentials) *http.Request {
 		augmentRequestQuery(request, values)
 	}
 
-	prepareRequestV2(request, keys)
+	this.prepareRequestV2(request, keys)
 
 	stringToSign := stringToSignV2(request)
 	signature := signatureV2(stringToSign, keys)
@@ -110,29 +283,48 @@ func Sign2(request *http.Request, credentials ...Credentials) *http.Request {
 	values.Set("Signature", signature)
 
 	augmentRequestQuery(request, values)
-
-	return request
+	return nil
 }
 
 // SignS3 signs a request bound for Amazon S3 using their custom
 // HTTP authentication scheme.
 func SignS3(request *http.Request, credentials ...Credentials) *http.Request {
-	keys := chooseKeys(credentials)
+	keys, _ := chooseKeys(credentials)
+	defaultSigner.signS3(request, keys)
+	return request
+}
 
+// SignS3E is like SignS3, but reports missing credentials and unreadable
+// request bodies as errors.
+func SignS3E(request *http.Request, credentials ...Credentials) (*http.Request, error) {
+	keys, err := chooseKeys(credentials)
+	if err != nil {
+		return nil, err
+	}
+	if err := defaultSigner.signS3(request, keys); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func (this *Signer) signS3(request *http.Request, keys Credentials) error {
 	// Add the X-Amz-Security-Token header when using STS
 	if keys.SecurityToken != "" {
 		request.Header.Set("X-Amz-Security-Token", keys.SecurityToken)
 	}
 
-	prepareRequestS3(request)
+	this.prepareRequestS3(request)
 
-	stringToSign := stringToSignS3(request)
+	stringToSign, err := this.stringToSignS3(request)
+	if err != nil {
+		return err
+	}
 	signature := signatureS3(stringToSign, keys)
 
 	authHeader := "AWS " + keys.AccessKeyID + ":" + signature
 	request.Header.Set("Authorization", authHeader)
 
-	return request
+	return nil
 }
 
 // SignS3Url signs a GET request for a resource on Amazon S3 by appending
@@ -140,8 +332,22 @@ func SignS3(request *http.Request, credentials ...Credentials) *http.Request {
 // specify an expiration date for these signed requests. After that date,
 // a request signed with this method will be rejected by S3.
 func SignS3Url(request *http.Request, expire time.Time, credentials ...Credentials) *http.Request {
-	keys := chooseKeys(credentials)
+	keys, _ := chooseKeys(credentials)
+	signS3Url(request, expire, keys)
+	return request
+}
+
+// SignS3UrlE is like SignS3Url, but reports missing credentials as an error.
+func SignS3UrlE(request *http.Request, expire time.Time, credentials ...Credentials) (*http.Request, error) {
+	keys, err := chooseKeys(credentials)
+	if err != nil {
+		return nil, err
+	}
+	signS3Url(request, expire, keys)
+	return request, nil
+}
 
+func signS3Url(request *http.Request, expire time.Time, keys Credentials) {
 	stringToSign := stringToSignS3Url("GET", expire, request.URL.Path)
 	signature := signatureS3(stringToSign, keys)
 
@@ -150,8 +356,6 @@ func SignS3Url(request *http.Request, expire time.Time, credentials ...Credentia
 	query.Set("Signature", signature)
 	query.Set("Expires", timeToUnixEpochString(expire))
 	request.URL.RawQuery = query.Encode()
-
-	return request
 }
 
 // expired checks to see if the temporary credentials from an IAM role are
@@ -187,6 +391,23 @@ const (
 	envSecretKey       = "AWS_SECRET_KEY"
 	envSecretAccessKey = "AWS_SECRET_ACCESS_KEY"
 	envSecurityToken   = "AWS_SECURITY_TOKEN"
+
+	envSharedCredentialsFile = "AWS_SHARED_CREDENTIALS_FILE"
+	envProfile               = "AWS_PROFILE"
+)
+
+var (
+	// ErrNoCredentials is returned when no credentials were passed to a
+	// signing function and none could be found by the default provider.
+	ErrNoCredentials = errors.New("awsauth: no credentials available")
+
+	// ErrUnknownService is returned by SignE when the signing scheme for the
+	// service a request is bound for is not known.
+	ErrUnknownService = errors.New("awsauth: unknown service")
+
+	// ErrBodyRead is returned when the request body could not be read in
+	// order to compute its hash.
+	ErrBodyRead = errors.New("awsauth: unable to read request body")
 )
 
 var (
@@ -217,5 +438,6 @@ var (
 		"route53":              4,
 		"elasticloadbalancing": 4,
 		"email":                4,
+		"ses":                  4,
 	}
 )
@@ -1,6 +1,8 @@
 package awsauth
 
 import (
+	"context"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -151,6 +153,54 @@ func TestSign_ExistingCredentials_Version4(t *testing.T) {
 	}
 }
 
+func TestSignE_UnknownService(t *testing.T) {
+	assert := assertions.New(t)
+	request := newRequest("GET", "https://unknown.example.com", url.Values{})
+
+	signed, err := SignE(request, *testCredV4)
+
+	assert.So(signed, should.BeNil)
+	assert.So(errors.Is(err, ErrUnknownService), should.BeTrue)
+	assert.So(Sign(request, *testCredV4), should.BeNil)
+}
+
+func TestSignE_NoCredentials(t *testing.T) {
+	assert := assertions.New(t)
+
+	for _, sign := range []func(*http.Request, ...Credentials) (*http.Request, error){SignE, Sign2E, Sign3E, Sign4E, SignS3E} {
+		request := newRequest("GET", "https://iam.amazonaws.com", url.Values{})
+		signed, err := sign(request, Credentials{AccessKeyID: "id"})
+		assert.So(signed, should.BeNil)
+		assert.So(err, should.Equal, ErrNoCredentials)
+	}
+
+	_, err := SignS3UrlE(newRequest("GET", "https://s3.amazonaws.com", nil), time.Now(), Credentials{})
+	assert.So(err, should.Equal, ErrNoCredentials)
+}
+
+func TestSignE_UnreadableBody(t *testing.T) {
+	assert := assertions.New(t)
+
+	for _, sign := range []func(*http.Request, ...Credentials) (*http.Request, error){Sign4E, SignS3E} {
+		request, _ := http.NewRequest("PUT", "https://s3.amazonaws.com/bucket/key", ioutil.NopCloser(failingReader{}))
+		signed, err := sign(request, *testCredV4)
+		assert.So(signed, should.BeNil)
+		assert.So(errors.Is(err, ErrBodyRead), should.BeTrue)
+		assert.So(request.Header.Get("Authorization"), should.BeBlank)
+	}
+}
+
+func TestSignE_Success(t *testing.T) {
+	assert := assertions.New(t)
+	request := newRequest("GET", "https://iam.amazonaws.com", url.Values{})
+
+	signed, err := SignE(request, *testCredV4)
+
+	assert.So(err, should.BeNil)
+	assert.So(signed, should.Equal, request)
+	assert.So(signed.Header.Get("Authorization"), should.ContainSubstring, "Credential="+testCredV4.AccessKeyID)
+}
+
 func TestExpiration(t *testing.T) {
 	assert := assertions.New(t)
 	var credentials = &Credentials{}
@@ -171,6 +221,12 @@ func TestExpiration(t *testing.T) {
 	assert.So(credentials.expired(), should.BeTrue)
 }
 
+// newKeys produces a set of credentials based on the environment
+func newKeys() Credentials {
+	credentials, _ := defaultProvider.Retrieve(context.Background())
+	return credentials
+}
+
 func credentialsSet() bool {
 	var keys Credentials
 	keys = newKeys()
@@ -212,4 +268,10 @@ func signS3UrlAndDo(request *http.Request) *http.Response {
 	return response
 }
 
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
 var client = &http.Client{}
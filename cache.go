@@ -0,0 +1,215 @@
+package awsauth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CachedProvider holds on to the credentials of another provider until they
+// expire, so that signing many requests doesn't consult the environment or
+// the metadata service every time. Concurrent callers that find the cache
+// stale share a single refresh. It is safe for concurrent use.
+type CachedProvider struct {
+	Provider CredentialsProvider
+
+	// RefreshTimeout bounds how long a refresh may take, since it runs on
+	// behalf of every caller rather than under any one caller's context.
+	// Zero means one minute.
+	RefreshTimeout time.Duration
+
+	// RetryDelay is how long after a failed refresh the wrapped provider is
+	// left alone; until then callers get the credentials that are still
+	// valid or else the error of the failed refresh. Zero means five seconds.
+	RetryDelay time.Duration
+
+	mutex       sync.Mutex
+	credentials Credentials
+	cached      bool
+	err         error
+	retryAt     time.Time
+	refreshing  *refreshCall
+	stop        chan struct{}
+}
+
+type refreshCall struct {
+	done        chan struct{}
+	credentials Credentials
+	err         error
+}
+
+// NewCachedProvider creates a caching wrapper around the given provider.
+func NewCachedProvider(provider CredentialsProvider) *CachedProvider {
+	return &CachedProvider{Provider: provider}
+}
+
+// Retrieve returns the cached credentials, refreshing them from the wrapped
+// provider first if they are missing or expired. If the context is done
+// before the refresh completes, the context's error is returned while the
+// refresh carries on for the benefit of later callers. Shortly after a failed
+// refresh the error is returned again without consulting the wrapped provider.
+func (this *CachedProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	this.mutex.Lock()
+	if this.cached && !this.stale() {
+		credentials := this.credentials
+		this.mutex.Unlock()
+		return credentials, nil
+	}
+	if this.waitingToRetry() {
+		credentials, err := this.credentials, this.err
+		if !this.valid() {
+			credentials = Credentials{}
+		} else {
+			err = nil
+		}
+		this.mutex.Unlock()
+		return credentials, err
+	}
+	call := this.refresh()
+	this.mutex.Unlock()
+
+	select {
+	case <-call.done:
+		return call.credentials, call.err
+	case <-ctx.Done():
+		return Credentials{}, ctx.Err()
+	}
+}
+
+// IsExpired reports whether the cached credentials are missing or expired.
+func (this *CachedProvider) IsExpired() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return !this.cached || this.stale()
+}
+
+// Err returns the error of the most recent refresh, or nil if it succeeded.
+func (this *CachedProvider) Err() error {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.err
+}
+
+// StartBackgroundRefresh checks the cache at the given interval and refreshes
+// the credentials as soon as they are missing or expired, so that callers of
+// Retrieve don't have to wait for it. Call Stop to end the background refresh.
+func (this *CachedProvider) StartBackgroundRefresh(interval time.Duration) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.stop != nil {
+		return
+	}
+	this.stop = make(chan struct{})
+	go this.refreshPeriodically(interval, this.stop)
+}
+
+// Stop ends a background refresh started with StartBackgroundRefresh.
+func (this *CachedProvider) Stop() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.stop != nil {
+		close(this.stop)
+		this.stop = nil
+	}
+}
+
+func (this *CachedProvider) refreshPeriodically(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			this.mutex.Lock()
+			if (!this.cached || this.stale()) && !this.waitingToRetry() {
+				this.refresh()
+			}
+			this.mutex.Unlock()
+		}
+	}
+}
+
+// refresh returns the refresh in progress or starts a new one.
+// The caller must hold the mutex.
+func (this *CachedProvider) refresh() *refreshCall {
+	if this.refreshing != nil {
+		return this.refreshing
+	}
+
+	call := &refreshCall{done: make(chan struct{})}
+	this.refreshing = call
+
+	go func() {
+		// A refresh serves every waiting caller, so it must not be
+		// cancelled along with the context of the caller that started it.
+		ctx, cancel := context.WithTimeout(context.Background(), this.refreshTimeout())
+		credentials, err := this.Provider.Retrieve(ctx)
+		cancel()
+		if err != nil {
+			credentials = Credentials{}
+		}
+
+		this.mutex.Lock()
+		if err == nil {
+			this.credentials = credentials
+			this.cached = true
+			this.retryAt = time.Time{}
+		} else {
+			this.retryAt = time.Now().Add(this.retryDelay())
+		}
+		this.err = err
+		this.refreshing = nil
+		this.mutex.Unlock()
+
+		call.credentials, call.err = credentials, err
+		close(call.done)
+	}()
+
+	return call
+}
+
+// stale reports whether the cached credentials need to be refreshed.
+// While a refresh is under way, unexpired credentials remain usable and
+// the wrapped provider is left alone. The caller must hold the mutex.
+func (this *CachedProvider) stale() bool {
+	if this.credentials.expired() {
+		return true
+	}
+	if this.refreshing != nil {
+		return false
+	}
+	return this.Provider.IsExpired()
+}
+
+// waitingToRetry reports whether a refresh failed too recently to try
+// again. The caller must hold the mutex.
+func (this *CachedProvider) waitingToRetry() bool {
+	return this.refreshing == nil && time.Now().Before(this.retryAt)
+}
+
+// valid reports whether the cached credentials may still be used, even
+// though they are due to be refreshed. The caller must hold the mutex.
+func (this *CachedProvider) valid() bool {
+	expiration := this.credentials.Expiration
+	return this.cached && (expiration.IsZero() || time.Now().Before(expiration))
+}
+
+func (this *CachedProvider) refreshTimeout() time.Duration {
+	if this.RefreshTimeout > 0 {
+		return this.RefreshTimeout
+	}
+	return time.Minute
+}
+
+func (this *CachedProvider) retryDelay() time.Duration {
+	if this.RetryDelay > 0 {
+		return this.RetryDelay
+	}
+	return 5 * time.Second
+}
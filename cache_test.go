@@ -0,0 +1,227 @@
+package awsauth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/assertions/should"
+	"github.com/smartystreets/gunit"
+)
+
+func TestCachedProviderFixture(t *testing.T) {
+	gunit.Run(new(CachedProviderFixture), t)
+}
+
+type CachedProviderFixture struct {
+	*gunit.Fixture
+
+	source *countingProvider
+	cache  *CachedProvider
+}
+
+func (this *CachedProviderFixture) Setup() {
+	this.source = &countingProvider{credentials: Credentials{AccessKeyID: "id", SecretAccessKey: "secret"}}
+	this.cache = NewCachedProvider(this.source)
+}
+
+func (this *CachedProviderFixture) Teardown() {
+	this.cache.Stop()
+}
+
+func (this *CachedProviderFixture) TestCredentialsAreCached() {
+	first, err1 := this.cache.Retrieve(context.Background())
+	second, err2 := this.cache.Retrieve(context.Background())
+
+	this.So(err1, should.BeNil)
+	this.So(err2, should.BeNil)
+	this.So(first, should.Resemble, this.source.credentials)
+	this.So(second, should.Resemble, this.source.credentials)
+	this.So(this.source.count(), should.Equal, 1)
+	this.So(this.cache.IsExpired(), should.BeFalse)
+}
+
+func (this *CachedProviderFixture) TestExpiringCredentialsAreRefreshed() {
+	this.source.credentials.Expiration = time.Now().Add(time.Minute)
+
+	this.cache.Retrieve(context.Background())
+	this.So(this.cache.IsExpired(), should.BeTrue)
+
+	this.cache.Retrieve(context.Background())
+	this.So(this.source.count(), should.Equal, 2)
+}
+
+func (this *CachedProviderFixture) TestExpiredSourceIsRefreshed() {
+	this.cache.Retrieve(context.Background())
+	this.source.setExpired(true)
+
+	this.cache.Retrieve(context.Background())
+
+	this.So(this.source.count(), should.Equal, 2)
+}
+
+func (this *CachedProviderFixture) TestRefreshErrorIsReturnedAndExposed() {
+	this.source.err = errors.New("unavailable")
+
+	credentials, err := this.cache.Retrieve(context.Background())
+
+	this.So(err, should.Equal, this.source.err)
+	this.So(credentials, should.Resemble, Credentials{})
+	this.So(this.cache.Err(), should.Equal, this.source.err)
+	this.So(this.cache.IsExpired(), should.BeTrue)
+}
+
+func (this *CachedProviderFixture) TestFailedRefreshIsNotRetriedAtOnce() {
+	this.source.err = errors.New("unavailable")
+
+	this.cache.Retrieve(context.Background())
+	credentials, err := this.cache.Retrieve(context.Background())
+
+	this.So(err, should.Equal, this.source.err)
+	this.So(credentials, should.Resemble, Credentials{})
+	this.So(this.source.count(), should.Equal, 1)
+}
+
+func (this *CachedProviderFixture) TestValidCredentialsAreUsedUntilRetry() {
+	this.cache.Retrieve(context.Background())
+	this.source.setExpired(true)
+	this.source.err = errors.New("unavailable")
+
+	_, err := this.cache.Retrieve(context.Background())
+	this.So(err, should.Equal, this.source.err)
+
+	credentials, err := this.cache.Retrieve(context.Background())
+	this.So(err, should.BeNil)
+	this.So(credentials.AccessKeyID, should.Equal, "id")
+	this.So(this.source.count(), should.Equal, 2)
+}
+
+func (this *CachedProviderFixture) TestFailedRefreshIsRetriedAfterDelay() {
+	this.cache.RetryDelay = time.Millisecond
+	this.source.err = errors.New("unavailable")
+	this.cache.Retrieve(context.Background())
+
+	time.Sleep(5 * time.Millisecond)
+	this.source.err = nil
+	credentials, err := this.cache.Retrieve(context.Background())
+
+	this.So(err, should.BeNil)
+	this.So(credentials.AccessKeyID, should.Equal, "id")
+	this.So(this.source.count(), should.Equal, 2)
+}
+
+func (this *CachedProviderFixture) TestHungRefreshTimesOut() {
+	this.cache.RefreshTimeout = time.Millisecond
+	this.source.release = make(chan struct{})
+
+	credentials, err := this.cache.Retrieve(context.Background())
+
+	this.So(errors.Is(err, context.DeadlineExceeded), should.BeTrue)
+	this.So(credentials, should.Resemble, Credentials{})
+}
+
+func (this *CachedProviderFixture) TestConcurrentCallersShareOneRefresh() {
+	this.source.release = make(chan struct{})
+
+	var waiter sync.WaitGroup
+	results := make(chan Credentials, 50)
+	for i := 0; i < 50; i++ {
+		waiter.Add(1)
+		go func() {
+			defer waiter.Done()
+			credentials, _ := this.cache.Retrieve(context.Background())
+			results <- credentials
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	close(this.source.release)
+	waiter.Wait()
+	close(results)
+
+	this.So(this.source.count(), should.Equal, 1)
+	for credentials := range results {
+		this.So(credentials.AccessKeyID, should.Equal, "id")
+	}
+}
+
+func (this *CachedProviderFixture) TestCancelledCallerDoesNotCancelRefresh() {
+	this.source.release = make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := this.cache.Retrieve(ctx)
+	this.So(err, should.Equal, context.Canceled)
+
+	close(this.source.release)
+	credentials, err := this.cache.Retrieve(context.Background())
+
+	this.So(err, should.BeNil)
+	this.So(credentials.AccessKeyID, should.Equal, "id")
+	this.So(this.source.count(), should.Equal, 1)
+}
+
+func (this *CachedProviderFixture) TestBackgroundRefresh() {
+	this.cache.StartBackgroundRefresh(time.Millisecond)
+
+	for i := 0; i < 100 && this.cache.IsExpired(); i++ {
+		time.Sleep(time.Millisecond)
+	}
+	this.So(this.cache.IsExpired(), should.BeFalse)
+
+	this.source.setExpired(true)
+	for i := 0; i < 100 && this.source.count() < 2; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	this.So(this.source.count(), should.BeGreaterThanOrEqualTo, 2)
+}
+
+type countingProvider struct {
+	credentials Credentials
+	err         error
+	release     chan struct{}
+
+	mutex   sync.Mutex
+	calls   int
+	expired bool
+}
+
+func (this *countingProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	if this.release != nil {
+		select {
+		case <-this.release:
+		case <-ctx.Done():
+			return Credentials{}, ctx.Err()
+		}
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.calls++
+	this.expired = false
+	return this.credentials, this.err
+}
+
+func (this *countingProvider) IsExpired() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.expired
+}
+
+func (this *countingProvider) setExpired(expired bool) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.expired = expired
+}
+
+func (this *countingProvider) count() int {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.calls
+}
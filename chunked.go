@@ -0,0 +1,231 @@
+package awsauth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// signS3Chunked signs an S3 upload whose body is sent in aws-chunked
+// encoding, with each chunk signed in turn, starting from the seed signature
+// of the request itself. Only one chunk of the body is held in memory at a time.
+// The length of the body goes into the signature, so it must be known.
+// http://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html
+func (this *Signer) signS3Chunked(request *http.Request, chunkSize int, keys Credentials) error {
+	if request.Body == nil || request.ContentLength < 0 {
+		return errChunkedContentLength
+	}
+	if undeclared, err := hasUndeclaredBody(request); err != nil {
+		return err
+	} else if undeclared {
+		return errChunkedContentLength
+	}
+	if chunkSize < minChunkSizeS3 {
+		return errChunkSize
+	}
+
+	// Add the X-Amz-Security-Token header when using STS
+	if keys.SecurityToken != "" {
+		request.Header.Set("X-Amz-Security-Token", keys.SecurityToken)
+	}
+	if request.Header.Get("X-Amz-Date") == "" {
+		request.Header.Set("X-Amz-Date", this.timestampV4())
+	}
+	if request.URL.Path == "" {
+		request.URL.Path += "/"
+	}
+
+	decodedLength := request.ContentLength
+	encodedLength := chunkedContentLength(decodedLength, chunkSize)
+
+	encoding := "aws-chunked"
+	if existing := request.Header.Get("Content-Encoding"); existing != "" && existing != encoding {
+		encoding += "," + existing
+	}
+	request.Header.Set("Content-Encoding", encoding)
+	request.Header.Set("X-Amz-Decoded-Content-Length", strconv.FormatInt(decodedLength, 10))
+	request.Header.Set("X-Amz-Content-Sha256", streamingPayloadV4)
+	request.ContentLength = encodedLength
+
+	// Set these in header values to make them appear in the range of headers to sign
+	request.Header.Set("Host", request.Host)
+	request.Header.Set("Content-Length", strconv.FormatInt(encodedLength, 10))
+
+	signedHeaders := this.headersToSignV4(request)
+	for _, name := range []string{"content-encoding", "content-length"} {
+		if i := sort.SearchStrings(signedHeaders, name); i == len(signedHeaders) || signedHeaders[i] != name {
+			signedHeaders = append(signedHeaders, name)
+		}
+	}
+	sort.Strings(signedHeaders)
+
+	meta := new(metadata)
+	meta.signedHeaders = concat(";", signedHeaders...)
+	service, _ := this.serviceAndRegion(request, request.Host)
+	canonicalRequest := canonicalRequestV4(request, service, parseQuery(request.URL.RawQuery), signedHeaders, streamingPayloadV4)
+	this.logf("awsauth: canonical request:\n%s", canonicalRequest)
+	stringToSign := this.stringToSignV4(request, hashSHA256([]byte(canonicalRequest)), meta)
+
+	signingKey := signingKeyV4(keys.SecretAccessKey, meta.date, meta.region, meta.service)
+	seedSignature := signatureV4(signingKey, stringToSign)
+
+	request.Header.Set("Authorization", buildAuthHeaderV4(seedSignature, meta, keys))
+
+	newBody := func(source io.ReadCloser) io.ReadCloser {
+		return &chunkedBody{
+			source:        source,
+			chunkSize:     chunkSize,
+			decodedLength: decodedLength,
+			signingKey:    signingKey,
+			timestamp:     request.Header.Get("X-Amz-Date"),
+			scope:         meta.credentialScope,
+			previous:      seedSignature,
+		}
+	}
+
+	request.Body = newBody(request.Body)
+	if getBody := request.GetBody; getBody != nil {
+		request.GetBody = func() (io.ReadCloser, error) {
+			source, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return newBody(source), nil
+		}
+	}
+
+	return nil
+}
+
+// hasUndeclaredBody reports whether a request whose ContentLength is zero
+// nonetheless has a body, as one made from a reader of unknown length does.
+// The byte read to find out is put back in front of the rest of the body.
+func hasUndeclaredBody(request *http.Request) (bool, error) {
+	if request.ContentLength != 0 || request.Body == nil || request.Body == http.NoBody {
+		return false, nil
+	}
+	rest := request.Body
+	peek := make([]byte, 1)
+	n, err := io.ReadFull(rest, peek)
+	request.Body = &bufferedBodyV4{Reader: io.MultiReader(bytes.NewReader(peek[:n]), rest), rest: rest}
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// chunkedBody reads the source body a chunk at a time and frames each chunk
+// with its size and a signature chained from the signature before it.
+type chunkedBody struct {
+	source        io.ReadCloser
+	chunkSize     int
+	decodedLength int64
+	signingKey    []byte
+	timestamp     string
+	scope         string
+	previous      string
+
+	buffer   []byte
+	read     int64
+	pending  bytes.Buffer
+	finished bool
+	err      error
+}
+
+func (this *chunkedBody) Read(p []byte) (int, error) {
+	for this.pending.Len() == 0 && !this.finished && this.err == nil {
+		this.err = this.nextChunk()
+	}
+	if this.pending.Len() > 0 {
+		return this.pending.Read(p)
+	}
+	if this.err != nil {
+		return 0, this.err
+	}
+	return 0, io.EOF
+}
+
+func (this *chunkedBody) Close() error {
+	return this.source.Close()
+}
+
+func (this *chunkedBody) nextChunk() error {
+	if this.buffer == nil {
+		this.buffer = make([]byte, this.chunkSize)
+	}
+	n, err := io.ReadFull(this.source, this.buffer)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: %v", ErrBodyRead, err)
+	}
+	data := this.buffer[:n]
+	this.read += int64(n)
+
+	if this.read > this.decodedLength || (n == 0 && this.read != this.decodedLength) {
+		return errChunkedBodyLength
+	}
+
+	this.writeChunk(data)
+	if n == 0 {
+		this.finished = true
+	} else if this.read == this.decodedLength {
+		this.writeChunk(nil)
+		this.finished = true
+	}
+	return nil
+}
+
+func (this *chunkedBody) writeChunk(data []byte) {
+	signature := chunkSignatureV4(this.signingKey, this.timestamp, this.scope, this.previous, data)
+	this.previous = signature
+
+	this.pending.WriteString(strconv.FormatInt(int64(len(data)), 16))
+	this.pending.WriteString(";chunk-signature=")
+	this.pending.WriteString(signature)
+	this.pending.WriteString("\r\n")
+	this.pending.Write(data)
+	this.pending.WriteString("\r\n")
+}
+
+// chunkSignatureV4 signs one chunk of an aws-chunked body.
+func chunkSignatureV4(signingKey []byte, timestamp, scope, previous string, data []byte) string {
+	dataHash := sha256.Sum256(data)
+	stringToSign := concat("\n", "AWS4-HMAC-SHA256-PAYLOAD", timestamp, scope, previous, emptyPayloadHashV4, hex.EncodeToString(dataHash[:]))
+	return signatureV4(signingKey, stringToSign)
+}
+
+// chunkedContentLength calculates the length of an aws-chunked body that
+// carries the given number of bytes in chunks of the given size.
+func chunkedContentLength(decodedLength int64, chunkSize int) int64 {
+	frame := func(size int64) int64 {
+		return int64(len(strconv.FormatInt(size, 16))) + int64(len(";chunk-signature=")) + 64 + 2 + size + 2
+	}
+
+	size := int64(chunkSize)
+	length := (decodedLength / size) * frame(size)
+	if remainder := decodedLength % size; remainder > 0 {
+		length += frame(remainder)
+	}
+	return length + frame(0)
+}
+
+const (
+	streamingPayloadV4 = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	emptyPayloadHashV4 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	// DefaultChunkSize is a reasonable chunk size for SignS3Chunked.
+	DefaultChunkSize = 64 * 1024
+
+	minChunkSizeS3 = 8 * 1024
+)
+
+var (
+	errChunkedContentLength = errors.New("awsauth: chunked uploads require a body whose ContentLength is known")
+	errChunkedBodyLength    = errors.New("awsauth: chunked body length does not match its ContentLength")
+	errChunkSize            = errors.New("awsauth: chunks must be at least 8 KiB")
+)
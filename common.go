@@ -1,20 +1,21 @@
 package awsauth
 
 import (
-	"bufio"
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
+	"hash"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
-	"os"
+	"sort"
 	"strings"
 	"time"
 )
@@ -26,80 +27,23 @@ type location struct {
 
 var loc *location
 
-// serviceAndRegion parsers a hostname to find out which ones it is.
-// http://docs.aws.amazon.com/general/latest/gr/rande.html
+// serviceAndRegion parses a hostname to find out the name of the service
+// requests to it are signed for and the region they are signed for.
 func serviceAndRegion(host string) (service string, region string) {
-	// These are the defaults if the hostname doesn't suggest something else
-	region = "us-east-1"
-	service = "s3"
-
-	parts := strings.Split(host, ".")
-	if len(parts) == 4 {
-		// Either service.region.amazonaws.com or virtual-host.region.amazonaws.com
-		if parts[1] == "s3" {
-			service = "s3"
-		} else if strings.HasPrefix(parts[1], "s3-") {
-			region = parts[1][3:]
-			service = "s3"
-		} else {
-			service = parts[0]
-			region = parts[1]
-		}
-	} else if len(parts) == 5 {
-		service = parts[2]
-		region = parts[1]
-	} else {
-		// Either service.amazonaws.com or s3-region.amazonaws.com
-		if strings.HasPrefix(parts[0], "s3-") {
-			region = parts[0][3:]
-		} else {
-			service = parts[0]
-		}
-	}
-
-	if region == "external-1" {
-		region = "us-east-1"
-	}
-
-	return
-}
-
-// newKeys produces a set of credentials based on the environment
-func newKeys() (newCredentials Credentials) {
-	// First use credentials from environment variables
-	newCredentials.AccessKeyID = os.Getenv(envAccessKeyID)
-	if newCredentials.AccessKeyID == "" {
-		newCredentials.AccessKeyID = os.Getenv(envAccessKey)
-	}
-
-	newCredentials.SecretAccessKey = os.Getenv(envSecretAccessKey)
-	if newCredentials.SecretAccessKey == "" {
-		newCredentials.SecretAccessKey = os.Getenv(envSecretKey)
-	}
-
-	newCredentials.SecurityToken = os.Getenv(envSecurityToken)
-
-	// If there is no Access Key and you are on EC2, get the key from the role
-	if (newCredentials.AccessKeyID == "" || newCredentials.SecretAccessKey == "") && onEC2() {
-		newCredentials = *getIAMRoleCredentials()
-	}
-
-	// If the key is expiring, get a new key
-	if newCredentials.expired() && onEC2() {
-		newCredentials = *getIAMRoleCredentials()
-	}
-
-	return newCredentials
+	endpoint := ResolveEndpoint(host)
+	return endpoint.SigningName, endpoint.Region
 }
 
-// checkKeys gets credentials depending on if any were passed in as an argument
+// chooseKeys gets credentials depending on if any were passed in as an argument
 // or it makes new ones based on the environment.
-func chooseKeys(cred []Credentials) Credentials {
+func chooseKeys(cred []Credentials) (Credentials, error) {
 	if len(cred) == 0 {
-		return newKeys()
-	} else {
-		return cred[0]
+		return defaultSigner.keys(context.Background())
 	}
+	if cred[0].AccessKeyID == "" || cred[0].SecretAccessKey == "" {
+		return cred[0], ErrNoCredentials
+	}
+	return cred[0], nil
 }
 
 // onEC2 checks to see if the program is running on an EC2 instance.
@@ -124,91 +68,16 @@ func onEC2() bool {
 	return loc.ec2
 }
 
-// getIAMRoleList gets a list of the roles that are available to this instance
-func getIAMRoleList() []string {
-
-	var roles []string
-	url := "http://169.254.169.254/latest/meta-data/iam/security-credentials/"
-
-	client := &http.Client{}
-
-	request, err := http.NewRequest("GET", url, nil)
-
-	if err != nil {
-		return roles
-	}
-
-	response, err := client.Do(request)
-
-	if err != nil {
-		return roles
-	}
-	defer response.Body.Close()
-
-	scanner := bufio.NewScanner(response.Body)
-	for scanner.Scan() {
-		roles = append(roles, scanner.Text())
-	}
-	return roles
-}
-
-func getIAMRoleCredentials() *Credentials {
-
-	roles := getIAMRoleList()
-
-	if len(roles) < 1 {
-		return &Credentials{}
-	}
-
-	// Use the first role in the list
-	role := roles[0]
-
-	url := "http://169.254.169.254/latest/meta-data/iam/security-credentials/"
-
-	// Create the full URL of the role
-	var buffer bytes.Buffer
-	buffer.WriteString(url)
-	buffer.WriteString(role)
-	roleURL := buffer.String()
-
-	// Get the role
-	roleRequest, err := http.NewRequest("GET", roleURL, nil)
-
-	if err != nil {
-		return &Credentials{}
-	}
-
-	client := &http.Client{}
-	roleResponse, err := client.Do(roleRequest)
-
-	if err != nil {
-		return &Credentials{}
-	}
-	defer roleResponse.Body.Close()
-
-	roleBuffer := new(bytes.Buffer)
-	roleBuffer.ReadFrom(roleResponse.Body)
-
-	credentials := Credentials{}
-
-	err = json.Unmarshal(roleBuffer.Bytes(), &credentials)
-
-	if err != nil {
-		return &Credentials{}
-	}
-
-	return &credentials
-
-}
-
+// augmentRequestQuery sets the given parameters in the query string of the
+// request, in place of any it has of the same name, and keeps the rest of
+// its parameters, repeated ones included.
 func augmentRequestQuery(request *http.Request, values url.Values) *http.Request {
-	for key, array := range request.URL.Query() {
-		for _, value := range array {
-			values.Set(key, value)
-		}
+	query := parseQuery(request.URL.RawQuery)
+	for key, array := range values {
+		query[key] = array
 	}
 
-	request.URL.RawQuery = values.Encode()
+	request.URL.RawQuery = normquery(query)
 
 	return request
 }
@@ -237,13 +106,61 @@ func hashMD5(content []byte) string {
 	return base64.StdEncoding.EncodeToString(h.Sum(nil))
 }
 
-func readAndReplaceBody(request *http.Request) []byte {
+func readAndReplaceBody(request *http.Request) ([]byte, error) {
 	if request.Body == nil {
-		return []byte{}
+		return []byte{}, nil
 	}
-	payload, _ := ioutil.ReadAll(request.Body)
+	payload, err := ioutil.ReadAll(request.Body)
 	request.Body = ioutil.NopCloser(bytes.NewReader(payload))
-	return payload
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBodyRead, err)
+	}
+	return payload, nil
+}
+
+// hashBody feeds the request body to the hash and returns the number of
+// bytes hashed. Bodies that can be rewound, because they implement io.Seeker
+// or the request has a GetBody function, are streamed through the hash and
+// left ready to be sent; any other body is buffered with readAndReplaceBody.
+func hashBody(request *http.Request, h hash.Hash) (int64, error) {
+	if request.Body == nil || request.Body == http.NoBody {
+		return 0, nil
+	}
+
+	if seeker, ok := request.Body.(io.Seeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrBodyRead, err)
+		}
+		n, err := io.Copy(h, request.Body)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrBodyRead, err)
+		}
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrBodyRead, err)
+		}
+		return n, nil
+	}
+
+	if request.GetBody != nil {
+		body, err := request.GetBody()
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrBodyRead, err)
+		}
+		defer body.Close()
+		n, err := io.Copy(h, body)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrBodyRead, err)
+		}
+		return n, nil
+	}
+
+	payload, err := readAndReplaceBody(request)
+	if err != nil {
+		return 0, err
+	}
+	h.Write(payload)
+	return int64(len(payload)), nil
 }
 
 func concat(delim string, str ...string) string {
@@ -254,37 +171,44 @@ var now = func() time.Time {
 	return time.Now().UTC()
 }
 
+// normuri percent-encodes each segment of a path as sent in a request,
+// keeping the escapes already in it.
 func normuri(uri string) string {
+	return encodePath(uri, true)
+}
+
+// encodePath percent-encodes the characters of each segment of a path that
+// are outside the unreserved set. With keepEscapes, the escapes already in the
+// path are kept (in upper case) instead of having their '%' encoded again.
+func encodePath(uri string, keepEscapes bool) string {
 	parts := strings.Split(uri, "/")
 	for i := range parts {
-		parts[i] = encodePathFrag(parts[i])
+		parts[i] = encodePathFrag(parts[i], keepEscapes)
 	}
 	return strings.Join(parts, "/")
 }
 
-func encodePathFrag(s string) string {
-	hexCount := 0
+func encodePathFrag(s string, keepEscapes bool) string {
+	var t strings.Builder
 	for i := 0; i < len(s); i++ {
 		c := s[i]
-		if shouldEscape(c) {
-			hexCount++
+		switch {
+		case keepEscapes && c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			t.WriteString(strings.ToUpper(s[i : i+3]))
+			i += 2
+		case shouldEscape(c):
+			t.WriteByte('%')
+			t.WriteByte("0123456789ABCDEF"[c>>4])
+			t.WriteByte("0123456789ABCDEF"[c&15])
+		default:
+			t.WriteByte(c)
 		}
 	}
-	t := make([]byte, len(s)+2*hexCount)
-	j := 0
-	for i := 0; i < len(s); i++ {
-		c := s[i]
-		if shouldEscape(c) {
-			t[j] = '%'
-			t[j+1] = "0123456789ABCDEF"[c>>4]
-			t[j+2] = "0123456789ABCDEF"[c&15]
-			j += 3
-		} else {
-			t[j] = c
-			j++
-		}
-	}
-	return string(t)
+	return t.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
 }
 
 func shouldEscape(c byte) bool {
@@ -300,12 +224,53 @@ func shouldEscape(c byte) bool {
 	return true
 }
 
+// normquery encodes query parameters as AWS canonicalizes them: names and
+// values percent-encoded as in RFC 3986, sorted by name and then by value,
+// with every value of a repeated parameter kept and "name=" for an empty value.
 func normquery(v url.Values) string {
-	queryString := v.Encode()
+	type parameter struct{ key, value string }
+	var parameters []parameter
+	for key, values := range v {
+		for _, value := range values {
+			parameters = append(parameters, parameter{encodePathFrag(key, false), encodePathFrag(value, false)})
+		}
+	}
+	sort.Slice(parameters, func(i, j int) bool {
+		if parameters[i].key != parameters[j].key {
+			return parameters[i].key < parameters[j].key
+		}
+		return parameters[i].value < parameters[j].value
+	})
+
+	pairs := make([]string, len(parameters))
+	for i, parameter := range parameters {
+		pairs[i] = parameter.key + "=" + parameter.value
+	}
+	return strings.Join(pairs, "&")
+}
 
-	// Go encodes a space as '+' but Amazon requires '%20'. Luckily any '+' in the
-	// original query string has been percent escaped so all '+' chars that are left
-	// were originally spaces.
+// parseQuery reads a query string like url.ParseQuery, but keeps the
+// parameters that it drops, those with a semicolon or a malformed escape
+// in them, so that they are signed as they are sent.
+func parseQuery(rawQuery string) url.Values {
+	values := url.Values{}
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		keyValue := strings.SplitN(pair, "=", 2)
+		key, value := unescapeQuery(keyValue[0]), ""
+		if len(keyValue) == 2 {
+			value = unescapeQuery(keyValue[1])
+		}
+		values[key] = append(values[key], value)
+	}
+	return values
+}
 
-	return strings.Replace(queryString, "+", "%20", -1)
+func unescapeQuery(s string) string {
+	if unescaped, err := url.QueryUnescape(s); err == nil {
+		return unescaped
+	}
+	return s
 }
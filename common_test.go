@@ -61,9 +61,18 @@ func (this *CommonFixture) TestURINormalization() {
 
 	this.So(normuri("/ /foo"), should.Equal, "/%20/foo")
 	this.So(normuri("/(foo)"), should.Equal, "/%28foo%29")
+	this.So(normuri("/%2b%20/100%"), should.Equal, "/%2B%20/100%25")
 
 	this.So(
 		normquery(url.Values{"p": []string{" +&;-=._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"}}),
 		should.Equal,
 		"p=%20%2B%26%3B-%3D._~0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz")
 }
+
+func (this *CommonFixture) TestQueryCanonicalization() {
+	this.So(normquery(parseQuery("b=2&a=2&a=1&a-b=x&c&d=&e=a=b")), should.Equal, "a=1&a=2&a-b=x&b=2&c=&d=&e=a%3Db")
+	this.So(normquery(parseQuery("Param1=value2&Param1=Value1")), should.Equal, "Param1=Value1&Param1=value2")
+	this.So(normquery(parseQuery("q=a+b%20c&ሴ=~*")), should.Equal, "%E1%88%B4=~%2A&q=a%20b%20c")
+	this.So(normquery(parseQuery("acl;x=1&&bad=100%")), should.Equal, "acl%3Bx=1&bad=100%25")
+	this.So(normquery(parseQuery("")), should.BeBlank)
+}
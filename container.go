@@ -0,0 +1,158 @@
+package awsauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ContainerProvider retrieves the task role credentials that ECS and Fargate
+// serve to containers. The endpoint is taken from AWS_CONTAINER_CREDENTIALS_RELATIVE_URI
+// or AWS_CONTAINER_CREDENTIALS_FULL_URI, and the Authorization header, if any,
+// from AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE or AWS_CONTAINER_AUTHORIZATION_TOKEN.
+type ContainerProvider struct {
+	// Client performs the credentials requests. Defaults to a client with
+	// a short timeout.
+	Client *http.Client
+
+	mutex       sync.Mutex
+	credentials Credentials
+}
+
+// Retrieve requests the task's credentials from the container credentials endpoint.
+func (this *ContainerProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.credentials = Credentials{}
+
+	address, err := containerCredentialsURL()
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	request, err := http.NewRequest("GET", address, nil)
+	if err != nil {
+		return Credentials{}, err
+	}
+	request = request.WithContext(ctx)
+
+	token, err := containerAuthorizationToken()
+	if err != nil {
+		return Credentials{}, err
+	}
+	if token != "" {
+		request.Header.Set("Authorization", token)
+	}
+
+	response, err := this.client().Do(request)
+	if err != nil {
+		return Credentials{}, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return Credentials{}, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return Credentials{}, fmt.Errorf("awsauth: container credentials request returned %s", response.Status)
+	}
+
+	credentials := Credentials{}
+	if err := json.Unmarshal(body, &credentials); err != nil {
+		return Credentials{}, err
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return Credentials{}, errContainerCredentialsNotFound
+	}
+
+	this.credentials = credentials
+	return credentials, nil
+}
+
+// IsExpired reports whether the task credentials are missing or within
+// the expiration window.
+func (this *ContainerProvider) IsExpired() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.credentials.AccessKeyID == "" || this.credentials.expired()
+}
+
+func (this *ContainerProvider) client() *http.Client {
+	if this.Client != nil {
+		return this.Client
+	}
+	return metadataClient
+}
+
+// containerCredentialsURL builds the credentials endpoint from the environment.
+// A full URI must either use HTTPS or point at a loopback or ECS address so
+// that the authorization token is never sent in the clear to another host.
+func containerCredentialsURL() (string, error) {
+	if relative := os.Getenv(envContainerCredentialsRelativeURI); relative != "" {
+		return containerCredentialsEndpoint + relative, nil
+	}
+
+	full := os.Getenv(envContainerCredentialsFullURI)
+	if full == "" {
+		return "", errNotInContainer
+	}
+
+	parsed, err := url.Parse(full)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Scheme == "https" {
+		return full, nil
+	}
+	if parsed.Scheme == "http" && isAllowedContainerHost(parsed.Hostname()) {
+		return full, nil
+	}
+	return "", fmt.Errorf("awsauth: container credentials host %q is not allowed", parsed.Host)
+}
+
+func isAllowedContainerHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	return ip.IsLoopback() || ip.Equal(net.ParseIP("169.254.170.2")) || ip.Equal(net.ParseIP("169.254.170.23"))
+}
+
+func containerAuthorizationToken() (string, error) {
+	if filename := os.Getenv(envContainerAuthorizationTokenFile); filename != "" {
+		contents, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(contents)), nil
+	}
+	return os.Getenv(envContainerAuthorizationToken), nil
+}
+
+const (
+	containerCredentialsEndpoint = "http://169.254.170.2"
+
+	envContainerCredentialsRelativeURI = "AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"
+	envContainerCredentialsFullURI     = "AWS_CONTAINER_CREDENTIALS_FULL_URI"
+	envContainerAuthorizationToken     = "AWS_CONTAINER_AUTHORIZATION_TOKEN"
+	envContainerAuthorizationTokenFile = "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"
+)
+
+var (
+	errNotInContainer               = errors.New("awsauth: container credentials endpoint not configured")
+	errContainerCredentialsNotFound = errors.New("awsauth: no credentials available from container endpoint")
+)
@@ -0,0 +1,145 @@
+package awsauth
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smartystreets/assertions/should"
+	"github.com/smartystreets/gunit"
+)
+
+func TestContainerProviderFixture(t *testing.T) {
+	gunit.RunSequential(new(ContainerProviderFixture), t)
+}
+
+type ContainerProviderFixture struct {
+	*gunit.Fixture
+
+	server        *httptest.Server
+	authorization string
+	environment   map[string]string
+	directory     string
+}
+
+func (this *ContainerProviderFixture) Setup() {
+	this.server = httptest.NewServer(http.HandlerFunc(this.serveCredentials))
+	this.directory, _ = ioutil.TempDir("", "awsauth")
+
+	this.environment = map[string]string{}
+	for _, name := range []string{
+		envContainerCredentialsRelativeURI,
+		envContainerCredentialsFullURI,
+		envContainerAuthorizationToken,
+		envContainerAuthorizationTokenFile,
+	} {
+		this.environment[name] = os.Getenv(name)
+		os.Unsetenv(name)
+	}
+}
+
+func (this *ContainerProviderFixture) Teardown() {
+	this.server.Close()
+	os.RemoveAll(this.directory)
+	for name, value := range this.environment {
+		os.Setenv(name, value)
+	}
+}
+
+func (this *ContainerProviderFixture) serveCredentials(response http.ResponseWriter, request *http.Request) {
+	this.authorization = request.Header.Get("Authorization")
+	if request.URL.Path != "/v2/credentials/task" {
+		http.NotFound(response, request)
+		return
+	}
+	response.Write([]byte(`{
+		"RoleArn": "arn:aws:iam::123456789012:role/task",
+		"AccessKeyId": "task-id",
+		"SecretAccessKey": "task-secret",
+		"Token": "task-token",
+		"Expiration": "2099-01-01T00:00:00Z"
+	}`))
+}
+
+func (this *ContainerProviderFixture) TestCredentialsFromFullURI() {
+	os.Setenv(envContainerCredentialsFullURI, this.server.URL+"/v2/credentials/task")
+	provider := &ContainerProvider{}
+
+	credentials, err := provider.Retrieve(context.Background())
+
+	this.So(err, should.BeNil)
+	this.So(credentials.AccessKeyID, should.Equal, "task-id")
+	this.So(credentials.SecretAccessKey, should.Equal, "task-secret")
+	this.So(credentials.SecurityToken, should.Equal, "task-token")
+	this.So(credentials.Expiration.Year(), should.Equal, 2099)
+	this.So(this.authorization, should.BeBlank)
+	this.So(provider.IsExpired(), should.BeFalse)
+}
+
+func (this *ContainerProviderFixture) TestAuthorizationTokenFromEnvironment() {
+	os.Setenv(envContainerCredentialsFullURI, this.server.URL+"/v2/credentials/task")
+	os.Setenv(envContainerAuthorizationToken, "secret-token")
+
+	_, err := (&ContainerProvider{}).Retrieve(context.Background())
+
+	this.So(err, should.BeNil)
+	this.So(this.authorization, should.Equal, "secret-token")
+}
+
+func (this *ContainerProviderFixture) TestAuthorizationTokenFileTakesPrecedence() {
+	filename := filepath.Join(this.directory, "token")
+	ioutil.WriteFile(filename, []byte("token-from-file\n"), 0600)
+	os.Setenv(envContainerCredentialsFullURI, this.server.URL+"/v2/credentials/task")
+	os.Setenv(envContainerAuthorizationToken, "secret-token")
+	os.Setenv(envContainerAuthorizationTokenFile, filename)
+
+	_, err := (&ContainerProvider{}).Retrieve(context.Background())
+
+	this.So(err, should.BeNil)
+	this.So(this.authorization, should.Equal, "token-from-file")
+}
+
+func (this *ContainerProviderFixture) TestFailedRequest() {
+	os.Setenv(envContainerCredentialsFullURI, this.server.URL+"/missing")
+	provider := &ContainerProvider{}
+
+	_, err := provider.Retrieve(context.Background())
+
+	this.So(err, should.NotBeNil)
+	this.So(provider.IsExpired(), should.BeTrue)
+}
+
+func (this *ContainerProviderFixture) TestNotConfigured() {
+	_, err := (&ContainerProvider{}).Retrieve(context.Background())
+
+	this.So(err, should.Equal, errNotInContainer)
+}
+
+func (this *ContainerProviderFixture) TestRelativeURI() {
+	os.Setenv(envContainerCredentialsRelativeURI, "/v2/credentials/task")
+
+	address, err := containerCredentialsURL()
+
+	this.So(err, should.BeNil)
+	this.So(address, should.Equal, "http://169.254.170.2/v2/credentials/task")
+}
+
+func (this *ContainerProviderFixture) TestFullURIHostRestrictions() {
+	for address, allowed := range map[string]bool{
+		"http://localhost:8080/credentials":    true,
+		"http://127.0.0.1/credentials":         true,
+		"http://[::1]/credentials":             true,
+		"http://169.254.170.23/v1/credentials": true,
+		"https://credentials.example.com/":     true,
+		"http://credentials.example.com/":      false,
+		"http://10.0.0.1/credentials":          false,
+	} {
+		os.Setenv(envContainerCredentialsFullURI, address)
+		_, err := containerCredentialsURL()
+		this.So(err == nil, should.Equal, allowed)
+	}
+}
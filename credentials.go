@@ -0,0 +1,189 @@
+package awsauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// CredentialsProvider is implemented by anything that can supply AWS
+// credentials for signing requests: the environment, an EC2 instance role,
+// a secrets vault, a fake in a unit test, etc.
+type CredentialsProvider interface {
+	// Retrieve returns a set of credentials or an error if the provider
+	// was unable to produce them.
+	Retrieve(ctx context.Context) (Credentials, error)
+
+	// IsExpired reports whether the credentials most recently returned
+	// by Retrieve should be retrieved again.
+	IsExpired() bool
+}
+
+// StaticProvider always returns the same, explicitly configured credentials.
+type StaticProvider struct {
+	Value Credentials
+}
+
+// NewStaticProvider creates a provider for a fixed set of credentials.
+func NewStaticProvider(credentials Credentials) *StaticProvider {
+	return &StaticProvider{Value: credentials}
+}
+
+// Retrieve returns the configured credentials.
+func (this *StaticProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	if this.Value.AccessKeyID == "" || this.Value.SecretAccessKey == "" {
+		return Credentials{}, errStaticCredentialsEmpty
+	}
+	return this.Value, nil
+}
+
+// IsExpired reports whether the configured credentials have expired.
+func (this *StaticProvider) IsExpired() bool {
+	return this.Value.expired()
+}
+
+// EnvProvider reads credentials from the AWS_ACCESS_KEY_ID (or AWS_ACCESS_KEY),
+// AWS_SECRET_ACCESS_KEY (or AWS_SECRET_KEY) and AWS_SECURITY_TOKEN environment
+// variables.
+type EnvProvider struct {
+	mutex     sync.Mutex
+	retrieved bool
+}
+
+// Retrieve reads the credentials from the environment.
+func (this *EnvProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.retrieved = false
+
+	credentials := Credentials{
+		AccessKeyID:     firstEnv(envAccessKeyID, envAccessKey),
+		SecretAccessKey: firstEnv(envSecretAccessKey, envSecretKey),
+		SecurityToken:   os.Getenv(envSecurityToken),
+	}
+
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return Credentials{}, errEnvCredentialsNotFound
+	}
+
+	this.retrieved = true
+	return credentials, nil
+}
+
+// IsExpired returns true until the environment has been successfully read.
+func (this *EnvProvider) IsExpired() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return !this.retrieved
+}
+
+// ChainProvider tries each of its providers in order and returns the
+// credentials of the first one that succeeds.
+type ChainProvider struct {
+	Providers []CredentialsProvider
+
+	mutex   sync.Mutex
+	current CredentialsProvider
+}
+
+// NewChainProvider creates a provider that consults the given providers in order.
+func NewChainProvider(providers ...CredentialsProvider) *ChainProvider {
+	return &ChainProvider{Providers: providers}
+}
+
+// NewDefaultProvider creates the chain that is used when no credentials are
+// passed to a signing function: first the environment, then the shared
+// credentials file, then a web identity token, then the ECS container role,
+// then the EC2 instance role.
+func NewDefaultProvider() *ChainProvider {
+	return NewChainProvider(
+		&EnvProvider{},
+		&SharedCredentialsProvider{},
+		&WebIdentityProvider{},
+		&ContainerProvider{},
+		&EC2RoleProvider{},
+	)
+}
+
+// Retrieve returns the credentials of the first provider in the chain that
+// is able to supply them. If none is, the error wraps ErrNoCredentials along
+// with the error of each provider, except for those that aren't configured
+// where the program runs (no credentials in the environment, no credentials
+// file, not on EC2, and the like).
+func (this *ChainProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.current = nil
+	var failures []error
+
+	for _, provider := range this.Providers {
+		if err := ctx.Err(); err != nil {
+			return Credentials{}, err
+		}
+
+		credentials, err := provider.Retrieve(ctx)
+		if err != nil {
+			if !notConfigured(err) {
+				failures = append(failures, fmt.Errorf("%T: %w", provider, err))
+			}
+			continue
+		}
+
+		this.current = provider
+		return credentials, nil
+	}
+
+	if len(failures) == 0 {
+		return Credentials{}, ErrNoCredentials
+	}
+	return Credentials{}, fmt.Errorf("%w: %w", ErrNoCredentials, errors.Join(failures...))
+}
+
+// IsExpired defers to the provider that supplied the most recent credentials.
+func (this *ChainProvider) IsExpired() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.current == nil || this.current.IsExpired()
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// notConfigured reports whether the error of a provider only means that its
+// source of credentials doesn't exist where the program runs.
+func notConfigured(err error) bool {
+	for _, target := range []error{
+		errEnvCredentialsNotFound,
+		errSharedCredentialsNotFound,
+		errHomeDirectoryNotFound,
+		errWebIdentityNotConfigured,
+		errNotInContainer,
+		errNotOnEC2,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultProvider supplies the credentials for signing functions that are
+// called without any.
+var defaultProvider = NewCachedProvider(NewDefaultProvider())
+
+var (
+	errStaticCredentialsEmpty = errors.New("awsauth: static credentials are empty")
+	errEnvCredentialsNotFound = errors.New("awsauth: credentials not found in environment")
+)
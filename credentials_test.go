@@ -0,0 +1,186 @@
+package awsauth
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smartystreets/assertions/should"
+	"github.com/smartystreets/gunit"
+)
+
+func TestCredentialsProviderFixture(t *testing.T) {
+	gunit.RunSequential(new(CredentialsProviderFixture), t)
+}
+
+type CredentialsProviderFixture struct {
+	*gunit.Fixture
+
+	environment map[string]string
+}
+
+func (this *CredentialsProviderFixture) Setup() {
+	this.environment = map[string]string{}
+	for _, name := range []string{envAccessKey, envAccessKeyID, envSecretKey, envSecretAccessKey, envSecurityToken} {
+		this.environment[name] = os.Getenv(name)
+		os.Unsetenv(name)
+	}
+}
+
+func (this *CredentialsProviderFixture) Teardown() {
+	for name, value := range this.environment {
+		os.Setenv(name, value)
+	}
+}
+
+func (this *CredentialsProviderFixture) TestStaticProvider() {
+	provider := NewStaticProvider(*testCredV4)
+
+	credentials, err := provider.Retrieve(context.Background())
+
+	this.So(err, should.BeNil)
+	this.So(credentials, should.Resemble, *testCredV4)
+	this.So(provider.IsExpired(), should.BeFalse)
+}
+
+func (this *CredentialsProviderFixture) TestStaticProviderWithoutKeys() {
+	_, err := NewStaticProvider(Credentials{}).Retrieve(context.Background())
+
+	this.So(err, should.NotBeNil)
+}
+
+func (this *CredentialsProviderFixture) TestEnvProvider() {
+	os.Setenv(envAccessKeyID, "access")
+	os.Setenv(envSecretKey, "secret")
+	os.Setenv(envSecurityToken, "token")
+	provider := &EnvProvider{}
+
+	this.So(provider.IsExpired(), should.BeTrue)
+
+	credentials, err := provider.Retrieve(context.Background())
+
+	this.So(err, should.BeNil)
+	this.So(credentials, should.Resemble, Credentials{AccessKeyID: "access", SecretAccessKey: "secret", SecurityToken: "token"})
+	this.So(provider.IsExpired(), should.BeFalse)
+}
+
+func (this *CredentialsProviderFixture) TestEnvProviderWithoutKeys() {
+	os.Setenv(envAccessKeyID, "access")
+	provider := &EnvProvider{}
+
+	_, err := provider.Retrieve(context.Background())
+
+	this.So(err, should.Equal, errEnvCredentialsNotFound)
+	this.So(provider.IsExpired(), should.BeTrue)
+}
+
+func (this *CredentialsProviderFixture) TestChainReturnsFirstSuccessfulProvider() {
+	failing := &fakeProvider{err: errors.New("nope")}
+	first := &fakeProvider{credentials: Credentials{AccessKeyID: "first"}}
+	second := &fakeProvider{credentials: Credentials{AccessKeyID: "second"}}
+	chain := NewChainProvider(failing, first, second)
+
+	credentials, err := chain.Retrieve(context.Background())
+
+	this.So(err, should.BeNil)
+	this.So(credentials.AccessKeyID, should.Equal, "first")
+	this.So(failing.calls, should.Equal, 1)
+	this.So(first.calls, should.Equal, 1)
+	this.So(second.calls, should.Equal, 0)
+}
+
+func (this *CredentialsProviderFixture) TestChainWithoutValidProviders() {
+	failure := errors.New("nope")
+	chain := NewChainProvider(&fakeProvider{err: failure})
+
+	credentials, err := chain.Retrieve(context.Background())
+
+	this.So(errors.Is(err, ErrNoCredentials), should.BeTrue)
+	this.So(errors.Is(err, failure), should.BeTrue)
+	this.So(err.Error(), should.Equal, "awsauth: no credentials available: *awsauth.fakeProvider: nope")
+	this.So(credentials, should.Resemble, Credentials{})
+	this.So(chain.IsExpired(), should.BeTrue)
+}
+
+func (this *CredentialsProviderFixture) TestChainReportsErrorsOfConfiguredProviders() {
+	directory, _ := ioutil.TempDir("", "awsauth")
+	defer os.RemoveAll(directory)
+	malformed := filepath.Join(directory, "credentials")
+	ioutil.WriteFile(malformed, []byte("aws_access_key_id = outside\n"), 0600)
+	denied := errors.New("sts request returned 403 Forbidden")
+	chain := NewChainProvider(
+		&EnvProvider{},
+		&SharedCredentialsProvider{Filename: malformed},
+		&fakeProvider{err: denied},
+	)
+
+	_, err := chain.Retrieve(context.Background())
+
+	this.So(errors.Is(err, ErrNoCredentials), should.BeTrue)
+	this.So(errors.Is(err, denied), should.BeTrue)
+	this.So(errors.Is(err, errEnvCredentialsNotFound), should.BeFalse)
+	this.So(err.Error(), should.ContainSubstring, "*awsauth.SharedCredentialsProvider: awsauth: "+malformed+": line 1: key outside of any section")
+	this.So(err.Error(), should.ContainSubstring, "*awsauth.fakeProvider: sts request returned 403 Forbidden")
+}
+
+func (this *CredentialsProviderFixture) TestChainSkipsProvidersThatAreNotConfigured() {
+	chain := NewChainProvider(&EnvProvider{}, &fakeProvider{err: errNotOnEC2})
+
+	_, err := chain.Retrieve(context.Background())
+
+	this.So(err, should.Equal, ErrNoCredentials)
+}
+
+func (this *CredentialsProviderFixture) TestChainExpirationFollowsActiveProvider() {
+	active := &fakeProvider{credentials: Credentials{AccessKeyID: "active"}}
+	chain := NewChainProvider(active)
+
+	this.So(chain.IsExpired(), should.BeTrue)
+
+	chain.Retrieve(context.Background())
+	this.So(chain.IsExpired(), should.BeFalse)
+
+	active.expired = true
+	this.So(chain.IsExpired(), should.BeTrue)
+}
+
+func (this *CredentialsProviderFixture) TestChainStopsWhenContextIsDone() {
+	provider := &fakeProvider{credentials: Credentials{AccessKeyID: "unused"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewChainProvider(provider).Retrieve(ctx)
+
+	this.So(err, should.Equal, context.Canceled)
+	this.So(provider.calls, should.Equal, 0)
+}
+
+func (this *CredentialsProviderFixture) TestDefaultProviderReadsEnvironment() {
+	os.Setenv(envAccessKey, "access")
+	os.Setenv(envSecretAccessKey, "secret")
+
+	credentials, err := NewDefaultProvider().Retrieve(context.Background())
+
+	this.So(err, should.BeNil)
+	this.So(credentials.AccessKeyID, should.Equal, "access")
+	this.So(credentials.SecretAccessKey, should.Equal, "secret")
+}
+
+type fakeProvider struct {
+	credentials Credentials
+	err         error
+	expired     bool
+	calls       int
+}
+
+func (this *fakeProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	this.calls++
+	return this.credentials, this.err
+}
+
+func (this *fakeProvider) IsExpired() bool {
+	return this.expired
+}
@@ -0,0 +1,275 @@
+package awsauth
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EC2RoleProvider retrieves the credentials of the first IAM role assigned
+// to the EC2 instance the program is running on. It uses the session-oriented
+// instance metadata service (IMDSv2) and only falls back to plain requests
+// (IMDSv1) when AllowV1Fallback is set.
+type EC2RoleProvider struct {
+	// Endpoint is the base URL of the instance metadata service.
+	// Defaults to http://169.254.169.254.
+	Endpoint string
+
+	// Client performs the metadata requests. Defaults to a client with a
+	// short timeout.
+	Client *http.Client
+
+	// AllowV1Fallback permits requests without a session token when the
+	// metadata service refuses to hand one out or the request for one times
+	// out, as it does when the response can't make the extra network hop
+	// into a container. The fallback is kept for as long as a token would
+	// have been.
+	AllowV1Fallback bool
+
+	// TokenTTL is the lifetime requested for session tokens, in whole
+	// seconds between one second and six hours, the range the metadata
+	// service accepts. Defaults to six hours.
+	TokenTTL time.Duration
+
+	// Clock is the function the expiration of session tokens is reckoned
+	// with. Defaults to the system clock.
+	Clock func() time.Time
+
+	mutex           sync.Mutex
+	credentials     Credentials
+	token           string
+	tokenless       bool
+	tokenExpiration time.Time
+}
+
+// Retrieve requests the role's credentials from the EC2 metadata service.
+func (this *EC2RoleProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.credentials = Credentials{}
+
+	if this.Endpoint == "" && !onEC2() {
+		return Credentials{}, errNotOnEC2
+	}
+
+	roles, err := this.roles(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+	if len(roles) < 1 {
+		return Credentials{}, errEC2RoleCredentialsNotFound
+	}
+
+	// Use the first role in the list
+	body, err := this.get(ctx, metadataCredentialsPath+roles[0])
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	credentials := Credentials{}
+	if err := json.Unmarshal(body, &credentials); err != nil {
+		return Credentials{}, err
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return Credentials{}, errEC2RoleCredentialsNotFound
+	}
+
+	this.credentials = credentials
+	return credentials, nil
+}
+
+// IsExpired reports whether the role credentials are missing or within
+// the expiration window.
+func (this *EC2RoleProvider) IsExpired() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.credentials.AccessKeyID == "" || this.credentials.expired()
+}
+
+// roles gets a list of the roles that are available to this instance
+func (this *EC2RoleProvider) roles(ctx context.Context) ([]string, error) {
+	body, err := this.get(ctx, metadataCredentialsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var roles []string
+	scanner := bufio.NewScanner(strings.NewReader(string(body)))
+	for scanner.Scan() {
+		if role := strings.TrimSpace(scanner.Text()); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles, scanner.Err()
+}
+
+// get performs a metadata request, attaching a session token when one
+// is available. A token rejected by the service is discarded and the
+// request is tried once more with a fresh one.
+func (this *EC2RoleProvider) get(ctx context.Context, path string) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		token, err := this.sessionToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		request, err := http.NewRequest("GET", this.endpoint()+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		request = request.WithContext(ctx)
+		if token != "" {
+			request.Header.Set(metadataTokenHeader, token)
+		}
+
+		response, err := this.client().Do(request)
+		if err != nil {
+			return nil, err
+		}
+		body, err := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if response.StatusCode == http.StatusUnauthorized && attempt == 0 && (token != "" || this.tokenless) {
+			this.token, this.tokenless = "", false
+			continue
+		}
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("awsauth: metadata request for %s returned %s", path, response.Status)
+		}
+		return body, nil
+	}
+}
+
+// sessionToken returns a cached IMDSv2 token, requesting a new one when the
+// cached token is missing or about to expire. An empty token with a nil error
+// means the request should be sent without one (IMDSv1).
+func (this *EC2RoleProvider) sessionToken(ctx context.Context) (string, error) {
+	if (this.token != "" || this.tokenless) && this.now().Before(this.tokenExpiration) {
+		return this.token, nil
+	}
+
+	ttl := this.tokenTTL()
+	token, err := this.requestToken(ctx, ttl)
+	if err != nil && !(this.AllowV1Fallback && tokensUnavailable(err)) {
+		return "", err
+	}
+
+	// Tokens are renewed a minute before they expire, or halfway through
+	// the lifetime of those that don't last two minutes.
+	refreshWindow := metadataTokenRefreshWindow
+	if refreshWindow > ttl/2 {
+		refreshWindow = ttl / 2
+	}
+	this.token = token
+	this.tokenless = err != nil
+	this.tokenExpiration = this.now().Add(ttl - refreshWindow)
+	return token, nil
+}
+
+// tokensUnavailable reports whether a failed token request means the
+// metadata service won't hand out tokens, rather than that it is in trouble.
+func tokensUnavailable(err error) bool {
+	var timeout interface{ Timeout() bool }
+	return errors.Is(err, errMetadataTokenRefused) ||
+		errors.As(err, &timeout) && timeout.Timeout()
+}
+
+func (this *EC2RoleProvider) requestToken(ctx context.Context, ttl time.Duration) (string, error) {
+	request, err := http.NewRequest("PUT", this.endpoint()+metadataTokenPath, nil)
+	if err != nil {
+		return "", err
+	}
+	request = request.WithContext(ctx)
+	request.Header.Set(metadataTokenTTLHeader, strconv.Itoa(int(ttl/time.Second)))
+
+	response, err := this.client().Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, response.Body)
+		if response.StatusCode == http.StatusForbidden || response.StatusCode == http.StatusNotFound {
+			return "", fmt.Errorf("%w: %s", errMetadataTokenRefused, response.Status)
+		}
+		return "", fmt.Errorf("awsauth: metadata token request returned %s", response.Status)
+	}
+
+	token, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	if len(token) == 0 {
+		return "", errEmptyMetadataToken
+	}
+	return string(token), nil
+}
+
+func (this *EC2RoleProvider) endpoint() string {
+	if this.Endpoint != "" {
+		return strings.TrimSuffix(this.Endpoint, "/")
+	}
+	return metadataEndpoint
+}
+
+func (this *EC2RoleProvider) client() *http.Client {
+	if this.Client != nil {
+		return this.Client
+	}
+	return metadataClient
+}
+
+func (this *EC2RoleProvider) now() time.Time {
+	if this.Clock != nil {
+		return this.Clock()
+	}
+	return now()
+}
+
+// tokenTTL returns the lifetime to request for session tokens, within the
+// range the metadata service accepts.
+func (this *EC2RoleProvider) tokenTTL() time.Duration {
+	switch {
+	case this.TokenTTL <= 0, this.TokenTTL > metadataTokenTTL:
+		return metadataTokenTTL
+	case this.TokenTTL < time.Second:
+		return time.Second
+	}
+	return this.TokenTTL.Truncate(time.Second)
+}
+
+const (
+	metadataEndpoint        = "http://169.254.169.254"
+	metadataTokenPath       = "/latest/api/token"
+	metadataCredentialsPath = "/latest/meta-data/iam/security-credentials/"
+
+	metadataTokenHeader    = "X-aws-ec2-metadata-token"
+	metadataTokenTTLHeader = "X-aws-ec2-metadata-token-ttl-seconds"
+
+	metadataTokenTTL           = 6 * time.Hour
+	metadataTokenRefreshWindow = time.Minute
+)
+
+var (
+	metadataClient = &http.Client{Timeout: 5 * time.Second}
+
+	errNotOnEC2                   = errors.New("awsauth: not running on EC2")
+	errEC2RoleCredentialsNotFound = errors.New("awsauth: no IAM role credentials available from EC2 metadata")
+	errEmptyMetadataToken         = errors.New("awsauth: metadata service returned an empty token")
+	errMetadataTokenRefused       = errors.New("awsauth: metadata token request refused")
+)
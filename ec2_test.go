@@ -0,0 +1,272 @@
+package awsauth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/assertions/should"
+	"github.com/smartystreets/gunit"
+)
+
+func TestEC2RoleProviderFixture(t *testing.T) {
+	gunit.Run(new(EC2RoleProviderFixture), t)
+}
+
+type EC2RoleProviderFixture struct {
+	*gunit.Fixture
+
+	metadata *fakeMetadataService
+	server   *httptest.Server
+	provider *EC2RoleProvider
+}
+
+func (this *EC2RoleProviderFixture) Setup() {
+	this.metadata = &fakeMetadataService{
+		tokensEnabled:  true,
+		tokensRequired: true,
+		role:           "test-role",
+		token:          "session",
+	}
+	this.server = httptest.NewServer(this.metadata)
+	this.provider = &EC2RoleProvider{Endpoint: this.server.URL}
+}
+
+func (this *EC2RoleProviderFixture) Teardown() {
+	this.server.Close()
+}
+
+func (this *EC2RoleProviderFixture) TestCredentialsRetrievedWithSessionToken() {
+	credentials, err := this.provider.Retrieve(context.Background())
+
+	this.So(err, should.BeNil)
+	this.So(credentials.AccessKeyID, should.Equal, "role-id")
+	this.So(credentials.SecretAccessKey, should.Equal, "role-secret")
+	this.So(credentials.SecurityToken, should.Equal, "role-token")
+	this.So(credentials.Expiration.IsZero(), should.BeFalse)
+	this.So(this.metadata.tokenRequests, should.Equal, 1)
+	this.So(this.metadata.lastTTL, should.Equal, "21600")
+	this.So(this.metadata.unauthenticated, should.Equal, 0)
+	this.So(this.provider.IsExpired(), should.BeFalse)
+}
+
+func (this *EC2RoleProviderFixture) TestSessionTokenIsCached() {
+	this.provider.Retrieve(context.Background())
+	this.provider.Retrieve(context.Background())
+
+	this.So(this.metadata.tokenRequests, should.Equal, 1)
+}
+
+func (this *EC2RoleProviderFixture) TestSessionTokenIsRenewedBeforeItExpires() {
+	clock := time.Now()
+	this.provider.Clock = func() time.Time { return clock }
+	this.provider.Retrieve(context.Background())
+
+	clock = clock.Add(metadataTokenTTL - metadataTokenRefreshWindow - time.Second)
+	this.provider.Retrieve(context.Background())
+	this.So(this.metadata.tokenRequests, should.Equal, 1)
+
+	clock = clock.Add(time.Second)
+	this.provider.Retrieve(context.Background())
+	this.So(this.metadata.tokenRequests, should.Equal, 2)
+}
+
+func (this *EC2RoleProviderFixture) TestTokenTTLIsKeptWithinRange() {
+	for ttl, expected := range map[time.Duration]string{
+		500 * time.Millisecond:                "1",
+		90*time.Second + 500*time.Millisecond: "90",
+		7 * time.Hour:                         "21600",
+	} {
+		metadata := &fakeMetadataService{tokensEnabled: true, tokensRequired: true, role: "test-role", token: "session"}
+		server := httptest.NewServer(metadata)
+		provider := &EC2RoleProvider{Endpoint: server.URL, TokenTTL: ttl}
+
+		_, err := provider.Retrieve(context.Background())
+		server.Close()
+
+		this.So(err, should.BeNil)
+		this.So(metadata.lastTTL, should.Equal, expected)
+	}
+}
+
+func (this *EC2RoleProviderFixture) TestShortLivedSessionTokenIsReusedForHalfItsLifetime() {
+	clock := time.Now()
+	this.provider.Clock = func() time.Time { return clock }
+	this.provider.TokenTTL = 30 * time.Second
+	this.provider.Retrieve(context.Background())
+
+	clock = clock.Add(14 * time.Second)
+	this.provider.Retrieve(context.Background())
+	this.So(this.metadata.tokenRequests, should.Equal, 1)
+
+	clock = clock.Add(time.Second)
+	this.provider.Retrieve(context.Background())
+	this.So(this.metadata.tokenRequests, should.Equal, 2)
+	this.So(this.metadata.lastTTL, should.Equal, "30")
+}
+
+func (this *EC2RoleProviderFixture) TestRejectedSessionTokenIsReplaced() {
+	this.provider.Retrieve(context.Background())
+	this.metadata.rotateToken()
+
+	credentials, err := this.provider.Retrieve(context.Background())
+
+	this.So(err, should.BeNil)
+	this.So(credentials.AccessKeyID, should.Equal, "role-id")
+	this.So(this.metadata.tokenRequests, should.Equal, 2)
+}
+
+func (this *EC2RoleProviderFixture) TestNoFallbackToV1ByDefault() {
+	this.metadata.tokensEnabled = false
+	this.metadata.tokensRequired = false
+
+	_, err := this.provider.Retrieve(context.Background())
+
+	this.So(err, should.NotBeNil)
+	this.So(this.metadata.unauthenticated, should.Equal, 0)
+	this.So(this.provider.IsExpired(), should.BeTrue)
+}
+
+func (this *EC2RoleProviderFixture) TestFallbackToV1WhenAllowed() {
+	this.metadata.tokensEnabled = false
+	this.metadata.tokensRequired = false
+	this.provider.AllowV1Fallback = true
+
+	credentials, err := this.provider.Retrieve(context.Background())
+
+	this.So(err, should.BeNil)
+	this.So(credentials.AccessKeyID, should.Equal, "role-id")
+	this.So(this.metadata.unauthenticated, should.Equal, 2)
+}
+
+func (this *EC2RoleProviderFixture) TestFallbackToV1IsRemembered() {
+	this.metadata.tokensEnabled = false
+	this.metadata.tokensRequired = false
+	this.provider.AllowV1Fallback = true
+
+	this.provider.Retrieve(context.Background())
+	_, err := this.provider.Retrieve(context.Background())
+
+	this.So(err, should.BeNil)
+	this.So(this.metadata.tokenRequests, should.Equal, 1)
+	this.So(this.metadata.unauthenticated, should.Equal, 4)
+}
+
+func (this *EC2RoleProviderFixture) TestFallbackToV1WhenTokenRequestTimesOut() {
+	this.metadata.tokensRequired = false
+	this.metadata.tokenHangs = true
+	this.provider.AllowV1Fallback = true
+	this.provider.Client = &http.Client{Timeout: 50 * time.Millisecond}
+
+	this.provider.Retrieve(context.Background())
+	credentials, err := this.provider.Retrieve(context.Background())
+
+	this.So(err, should.BeNil)
+	this.So(credentials.AccessKeyID, should.Equal, "role-id")
+	this.So(this.metadata.tokenRequests, should.Equal, 1)
+}
+
+func (this *EC2RoleProviderFixture) TestNoFallbackToV1WhenTokenServiceFails() {
+	this.metadata.tokensRequired = false
+	this.metadata.tokenStatus = http.StatusInternalServerError
+	this.provider.AllowV1Fallback = true
+
+	_, err := this.provider.Retrieve(context.Background())
+
+	this.So(err, should.NotBeNil)
+	this.So(this.metadata.unauthenticated, should.Equal, 0)
+}
+
+func (this *EC2RoleProviderFixture) TestV1FallbackEndsWhenTokensAreRequired() {
+	this.metadata.tokensEnabled = false
+	this.metadata.tokensRequired = false
+	this.provider.AllowV1Fallback = true
+	this.provider.Retrieve(context.Background())
+
+	this.metadata.tokensEnabled = true
+	this.metadata.tokensRequired = true
+	credentials, err := this.provider.Retrieve(context.Background())
+
+	this.So(err, should.BeNil)
+	this.So(credentials.AccessKeyID, should.Equal, "role-id")
+	this.So(this.metadata.tokenRequests, should.Equal, 2)
+}
+
+func (this *EC2RoleProviderFixture) TestNoRolesAssigned() {
+	this.metadata.role = ""
+
+	_, err := this.provider.Retrieve(context.Background())
+
+	this.So(err, should.Equal, errEC2RoleCredentialsNotFound)
+}
+
+type fakeMetadataService struct {
+	tokensEnabled  bool
+	tokensRequired bool
+	tokenStatus    int
+	tokenHangs     bool
+	role           string
+	token          string
+
+	tokenRequests   int
+	unauthenticated int
+	lastTTL         string
+}
+
+func (this *fakeMetadataService) rotateToken() {
+	this.token = "rotated"
+}
+
+func (this *fakeMetadataService) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	if request.URL.Path == metadataTokenPath {
+		if request.Method != "PUT" {
+			http.Error(response, "", http.StatusForbidden)
+			return
+		}
+		this.tokenRequests++
+		if this.tokenHangs {
+			<-request.Context().Done()
+			return
+		}
+		if this.tokenStatus != 0 {
+			http.Error(response, "", this.tokenStatus)
+			return
+		}
+		if !this.tokensEnabled {
+			http.Error(response, "", http.StatusForbidden)
+			return
+		}
+		this.lastTTL = request.Header.Get(metadataTokenTTLHeader)
+		response.Write([]byte(this.token))
+		return
+	}
+
+	token := request.Header.Get(metadataTokenHeader)
+	if token == "" {
+		this.unauthenticated++
+		if this.tokensRequired {
+			http.Error(response, "", http.StatusUnauthorized)
+			return
+		}
+	} else if token != this.token {
+		http.Error(response, "", http.StatusUnauthorized)
+		return
+	}
+
+	switch request.URL.Path {
+	case metadataCredentialsPath:
+		response.Write([]byte(this.role + "\n"))
+	case metadataCredentialsPath + "test-role":
+		response.Write([]byte(`{
+  "Code" : "Success",
+  "AccessKeyId" : "role-id",
+  "SecretAccessKey" : "role-secret",
+  "Token" : "role-token",
+  "Expiration" : "2099-01-01T00:00:00Z"
+}`))
+	default:
+		http.NotFound(response, request)
+	}
+}
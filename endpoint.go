@@ -0,0 +1,230 @@
+package awsauth
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+// Endpoint describes what the hostname of an AWS endpoint says about the
+// requests sent to it.
+type Endpoint struct {
+	// Partition is the group of regions the endpoint belongs to:
+	// "aws", "aws-cn", "aws-us-gov", "aws-iso" or "aws-iso-b".
+	Partition string
+
+	// Region is the region of the endpoint, or the region that requests
+	// to a global endpoint (such as IAM's) are signed for.
+	Region string
+
+	// Service is the endpoint prefix of the service, such as "email".
+	Service string
+
+	// SigningName is the name of the service that requests are signed
+	// for, such as "ses". It is usually the same as Service.
+	SigningName string
+
+	// Bucket is the S3 bucket of a virtual-hosted-style S3 endpoint.
+	Bucket string
+
+	// FIPS reports whether the endpoint uses FIPS 140-2 validated cryptography.
+	FIPS bool
+
+	// DualStack reports whether the endpoint can be reached over IPv6.
+	DualStack bool
+}
+
+// ResolveEndpoint works out the partition, region and service of an AWS
+// hostname, with or without a port. It understands regional and global
+// endpoints, dual-stack and FIPS endpoints, virtual-hosted S3 buckets
+// (including the legacy s3-region form), VPC interface endpoints and the
+// domains of the China, GovCloud and ISO partitions.
+// http://docs.aws.amazon.com/general/latest/gr/rande.html
+//
+// A hostname outside of AWS is taken to be named after its service, which
+// is signed for us-east-1.
+func ResolveEndpoint(host string) Endpoint {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	endpoint := Endpoint{Partition: "aws"}
+	labels, found := endpoint.trimDomain(host)
+	if !found {
+		endpoint.Service = strings.Split(host, ".")[0]
+		endpoint.SigningName = endpoint.Service
+		endpoint.Region = defaultRegions[endpoint.Partition]
+		return endpoint
+	}
+
+	endpoint.parseServiceAndRegion(endpoint.trimVPCEndpoint(labels))
+
+	if endpoint.Region == "" {
+		endpoint.Region = defaultRegions[endpoint.Partition]
+	} else if partition := partitionOfRegion(endpoint.Region); partition != "" {
+		endpoint.Partition = partition
+	}
+	endpoint.SigningName = endpoint.Service
+	if name, found := signingNames[endpoint.Service]; found {
+		endpoint.SigningName = name
+	}
+
+	return endpoint
+}
+
+// trimDomain removes the domain of the partition from the host and returns
+// the labels that are left.
+func (this *Endpoint) trimDomain(host string) ([]string, bool) {
+	for _, domain := range partitionDomains {
+		if strings.HasSuffix(host, domain.suffix) {
+			this.Partition = domain.partition
+			this.DualStack = domain.dualStack
+			return strings.Split(strings.TrimSuffix(host, domain.suffix), "."), true
+		}
+	}
+	return nil, false
+}
+
+// trimVPCEndpoint removes the labels that name a VPC interface endpoint,
+// as in vpce-1a2b3c4d-5e6f.s3.us-east-1.vpce.amazonaws.com.
+func (this *Endpoint) trimVPCEndpoint(labels []string) []string {
+	if len(labels) < 2 || labels[len(labels)-1] != "vpce" {
+		return labels
+	}
+	labels = labels[:len(labels)-1]
+	for i, label := range labels {
+		if strings.HasPrefix(label, "vpce-") {
+			// A bucket, access point or the like may precede the endpoint ID.
+			return append(labels[:i:i], labels[i+1:]...)
+		}
+	}
+	return labels
+}
+
+// parseServiceAndRegion works through the labels from the right: the
+// region (if any), a dual-stack marker and the service. Anything before the
+// service, such as a bucket, an API ID or an account ID, belongs to the
+// resource being addressed.
+func (this *Endpoint) parseServiceAndRegion(labels []string) {
+	at := len(labels) - 1
+	if at >= 0 {
+		if region, fips := trimFIPS(labels[at]); regionPattern.MatchString(region) || region == "us-gov" {
+			this.Region = regionOfLabel(region)
+			this.FIPS = this.FIPS || fips
+			at--
+		}
+	}
+	if at >= 0 && labels[at] == "dualstack" {
+		this.DualStack = true
+		at--
+	}
+	if at < 0 {
+		return
+	}
+
+	service, fips := trimFIPS(labels[at])
+	this.Service, this.FIPS = service, this.FIPS || fips
+	if s3Region := strings.TrimPrefix(service, "s3-"); s3Region != service {
+		// The legacy s3-region and s3-fips-region forms
+		s3Region, fips := trimFIPS(s3Region)
+		if regionPattern.MatchString(s3Region) || s3Region == "external-1" {
+			this.Service, this.Region = "s3", regionOfLabel(s3Region)
+			this.FIPS = this.FIPS || fips
+		}
+	}
+	if at > 0 {
+		if compound := labels[at-1] + "." + this.Service; signingNames[compound] != "" {
+			this.Service = compound
+			at--
+		}
+	}
+
+	if this.Service == "s3" && at > 0 {
+		this.Bucket = strings.Join(labels[:at], ".")
+	}
+}
+
+// trimFIPS removes the affix that marks a service or region label as FIPS.
+func trimFIPS(label string) (string, bool) {
+	if trimmed := strings.TrimSuffix(label, "-fips"); trimmed != label {
+		return trimmed, true
+	}
+	if trimmed := strings.TrimPrefix(label, "fips-"); trimmed != label {
+		return trimmed, true
+	}
+	return label, false
+}
+
+func regionOfLabel(label string) string {
+	switch label {
+	case "external-1":
+		return "us-east-1"
+	case "us-gov":
+		return "us-gov-west-1"
+	}
+	return label
+}
+
+func partitionOfRegion(region string) string {
+	for _, prefix := range regionPartitions {
+		if strings.HasPrefix(region, prefix.prefix) {
+			return prefix.partition
+		}
+	}
+	return ""
+}
+
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+
+var (
+	// partitionDomains lists the domains of each partition, longest first.
+	partitionDomains = []struct {
+		suffix    string
+		partition string
+		dualStack bool
+	}{
+		{".api.amazonwebservices.com.cn", "aws-cn", true},
+		{".amazonaws.com.cn", "aws-cn", false},
+		{".amazonaws.com", "aws", false},
+		{".api.aws", "aws", true},
+		{".c2s.ic.gov", "aws-iso", false},
+		{".sc2s.sgov.gov", "aws-iso-b", false},
+	}
+
+	regionPartitions = []struct {
+		prefix    string
+		partition string
+	}{
+		{"cn-", "aws-cn"},
+		{"us-gov-", "aws-us-gov"},
+		{"us-isob-", "aws-iso-b"},
+		{"us-iso-", "aws-iso"},
+	}
+
+	defaultRegions = map[string]string{
+		"aws":        "us-east-1",
+		"aws-cn":     "cn-north-1",
+		"aws-us-gov": "us-gov-west-1",
+		"aws-iso":    "us-iso-east-1",
+		"aws-iso-b":  "us-isob-east-1",
+	}
+
+	// signingNames holds the services whose signing name differs from
+	// their endpoint prefix.
+	signingNames = map[string]string{
+		"api.ecr":               "ecr",
+		"api.pricing":           "pricing",
+		"api.sagemaker":         "sagemaker",
+		"bedrock-agent-runtime": "bedrock",
+		"bedrock-runtime":       "bedrock",
+		"data-ats.iot":          "iotdata",
+		"data.iot":              "iotdata",
+		"email":                 "ses",
+		"iot":                   "execute-api",
+		"runtime.lex":           "lex",
+		"runtime.sagemaker":     "sagemaker",
+		"s3-control":            "s3",
+		"streams.dynamodb":      "dynamodb",
+	}
+)
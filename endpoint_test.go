@@ -0,0 +1,70 @@
+package awsauth
+
+import (
+	"testing"
+
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+)
+
+func TestResolveEndpoint(t *testing.T) {
+	for _, test := range endpointTests {
+		t.Run(test.host, func(t *testing.T) {
+			assertions.New(t).So(ResolveEndpoint(test.host), should.Resemble, test.expected)
+		})
+	}
+}
+
+var endpointTests = []struct {
+	host     string
+	expected Endpoint
+}{
+	// Global and regional endpoints
+	{"iam.amazonaws.com", Endpoint{Partition: "aws", Region: "us-east-1", Service: "iam", SigningName: "iam"}},
+	{"sts.amazonaws.com:443", Endpoint{Partition: "aws", Region: "us-east-1", Service: "sts", SigningName: "sts"}},
+	{"sqs.us-west-2.amazonaws.com", Endpoint{Partition: "aws", Region: "us-west-2", Service: "sqs", SigningName: "sqs"}},
+	{"SNS.EU-WEST-1.AMAZONAWS.COM.", Endpoint{Partition: "aws", Region: "eu-west-1", Service: "sns", SigningName: "sns"}},
+	{"email.eu-central-1.amazonaws.com", Endpoint{Partition: "aws", Region: "eu-central-1", Service: "email", SigningName: "ses"}},
+	{"runtime.sagemaker.ap-south-1.amazonaws.com", Endpoint{Partition: "aws", Region: "ap-south-1", Service: "runtime.sagemaker", SigningName: "sagemaker"}},
+	{"abc123.execute-api.us-east-2.amazonaws.com", Endpoint{Partition: "aws", Region: "us-east-2", Service: "execute-api", SigningName: "execute-api"}},
+	{"123456789012.s3-control.us-west-2.amazonaws.com", Endpoint{Partition: "aws", Region: "us-west-2", Service: "s3-control", SigningName: "s3"}},
+
+	// S3
+	{"s3.amazonaws.com", Endpoint{Partition: "aws", Region: "us-east-1", Service: "s3", SigningName: "s3"}},
+	{"bucketname.s3.amazonaws.com", Endpoint{Partition: "aws", Region: "us-east-1", Service: "s3", SigningName: "s3", Bucket: "bucketname"}},
+	{"s3.us-west-2.amazonaws.com", Endpoint{Partition: "aws", Region: "us-west-2", Service: "s3", SigningName: "s3"}},
+	{"bucket.s3.us-west-2.amazonaws.com", Endpoint{Partition: "aws", Region: "us-west-2", Service: "s3", SigningName: "s3", Bucket: "bucket"}},
+	{"my.dotted.bucket.s3.eu-west-1.amazonaws.com", Endpoint{Partition: "aws", Region: "eu-west-1", Service: "s3", SigningName: "s3", Bucket: "my.dotted.bucket"}},
+	{"s3-us-west-1.amazonaws.com", Endpoint{Partition: "aws", Region: "us-west-1", Service: "s3", SigningName: "s3"}},
+	{"bucket.s3-ap-northeast-1.amazonaws.com", Endpoint{Partition: "aws", Region: "ap-northeast-1", Service: "s3", SigningName: "s3", Bucket: "bucket"}},
+	{"s3-external-1.amazonaws.com", Endpoint{Partition: "aws", Region: "us-east-1", Service: "s3", SigningName: "s3"}},
+	{"s3.dualstack.eu-west-1.amazonaws.com", Endpoint{Partition: "aws", Region: "eu-west-1", Service: "s3", SigningName: "s3", DualStack: true}},
+	{"bucket.s3.dualstack.eu-west-1.amazonaws.com", Endpoint{Partition: "aws", Region: "eu-west-1", Service: "s3", SigningName: "s3", Bucket: "bucket", DualStack: true}},
+	{"bucket-fips.s3-fips.dualstack.us-east-1.amazonaws.com", Endpoint{Partition: "aws", Region: "us-east-1", Service: "s3", SigningName: "s3", Bucket: "bucket-fips", FIPS: true, DualStack: true}},
+
+	// FIPS
+	{"sqs-fips.us-east-1.amazonaws.com", Endpoint{Partition: "aws", Region: "us-east-1", Service: "sqs", SigningName: "sqs", FIPS: true}},
+	{"s3-fips-us-gov-west-1.amazonaws.com", Endpoint{Partition: "aws-us-gov", Region: "us-gov-west-1", Service: "s3", SigningName: "s3", FIPS: true}},
+	{"dynamodb.fips-us-gov-west-1.amazonaws.com", Endpoint{Partition: "aws-us-gov", Region: "us-gov-west-1", Service: "dynamodb", SigningName: "dynamodb", FIPS: true}},
+
+	// Other partitions
+	{"ec2.us-gov-west-1.amazonaws.com", Endpoint{Partition: "aws-us-gov", Region: "us-gov-west-1", Service: "ec2", SigningName: "ec2"}},
+	{"iam.us-gov.amazonaws.com", Endpoint{Partition: "aws-us-gov", Region: "us-gov-west-1", Service: "iam", SigningName: "iam"}},
+	{"ec2.cn-north-1.amazonaws.com.cn", Endpoint{Partition: "aws-cn", Region: "cn-north-1", Service: "ec2", SigningName: "ec2"}},
+	{"iam.amazonaws.com.cn", Endpoint{Partition: "aws-cn", Region: "cn-north-1", Service: "iam", SigningName: "iam"}},
+	{"bucket.s3.cn-northwest-1.amazonaws.com.cn", Endpoint{Partition: "aws-cn", Region: "cn-northwest-1", Service: "s3", SigningName: "s3", Bucket: "bucket"}},
+	{"ec2.us-iso-east-1.c2s.ic.gov", Endpoint{Partition: "aws-iso", Region: "us-iso-east-1", Service: "ec2", SigningName: "ec2"}},
+	{"sts.us-isob-east-1.sc2s.sgov.gov", Endpoint{Partition: "aws-iso-b", Region: "us-isob-east-1", Service: "sts", SigningName: "sts"}},
+
+	// Dual-stack domains
+	{"ec2.us-east-1.api.aws", Endpoint{Partition: "aws", Region: "us-east-1", Service: "ec2", SigningName: "ec2", DualStack: true}},
+	{"lambda.cn-north-1.api.amazonwebservices.com.cn", Endpoint{Partition: "aws-cn", Region: "cn-north-1", Service: "lambda", SigningName: "lambda", DualStack: true}},
+
+	// VPC interface endpoints
+	{"vpce-1a2b3c4d-5e6f.sqs.us-east-1.vpce.amazonaws.com", Endpoint{Partition: "aws", Region: "us-east-1", Service: "sqs", SigningName: "sqs"}},
+	{"bucket.vpce-1a2b3c4d-5e6f.s3.us-east-1.vpce.amazonaws.com", Endpoint{Partition: "aws", Region: "us-east-1", Service: "s3", SigningName: "s3", Bucket: "bucket"}},
+
+	// Outside of AWS
+	{"localhost:4566", Endpoint{Partition: "aws", Region: "us-east-1", Service: "localhost", SigningName: "localhost"}},
+	{"unknown.example.com", Endpoint{Partition: "aws", Region: "us-east-1", Service: "unknown", SigningName: "unknown"}},
+}
@@ -0,0 +1,121 @@
+package awsauth
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http"
+)
+
+// Authenticator is an http.Handler that lets only requests signed with a
+// known access key through to another handler, which makes it the starting
+// point of a service that speaks the AWS protocol, such as a stand-in for S3
+// or SQS. It accepts every signing scheme Verify does. The handler finds the
+// access key ID of the request with AccessKeyID:
+//
+//	handler := &awsauth.Authenticator{Handler: service, Secrets: lookup}
+type Authenticator struct {
+	// Handler serves the requests that are authenticated.
+	Handler http.Handler
+
+	// Secrets supplies the secret access key of each access key ID.
+	Secrets SecretLookup
+
+	// Signer verifies the requests, with its clock and maximum skew.
+	// Defaults to a Signer with no options: the time a request was signed
+	// at may be 15 minutes from now.
+	Signer *Signer
+
+	// Reject writes the response to a request that failed authentication.
+	// Defaults to an error response like the ones from AWS, which says what
+	// kind of failure it was but nothing more; the error itself goes to the
+	// Logger of the Signer, if any.
+	Reject func(response http.ResponseWriter, request *http.Request, err error)
+}
+
+// ServeHTTP authenticates the request and passes it on to the handler,
+// with the access key ID in its context, or else rejects it.
+func (this *Authenticator) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	accessKeyID, err := this.signer().Verify(request, this.Secrets)
+	if err != nil {
+		this.signer().logf("awsauth: rejected %s %s: %v", request.Method, request.URL.Path, err)
+		this.reject()(response, request, err)
+		return
+	}
+
+	ctx := context.WithValue(request.Context(), accessKeyIDKey{}, accessKeyID)
+	this.Handler.ServeHTTP(response, request.WithContext(ctx))
+}
+
+func (this *Authenticator) signer() *Signer {
+	if this.Signer != nil {
+		return this.Signer
+	}
+	return defaultSigner
+}
+
+func (this *Authenticator) reject() func(http.ResponseWriter, *http.Request, error) {
+	if this.Reject != nil {
+		return this.Reject
+	}
+	return rejectLikeAWS
+}
+
+// AccessKeyID returns the access key ID that an Authenticator found the
+// request with the context to be signed with, if any.
+func AccessKeyID(ctx context.Context) (string, bool) {
+	accessKeyID, ok := ctx.Value(accessKeyIDKey{}).(string)
+	return accessKeyID, ok
+}
+
+type accessKeyIDKey struct{}
+
+// rejectLikeAWS writes the XML error document AWS responds with when a
+// request fails authentication. The code and message are fixed for each kind
+// of failure, so that nothing the SecretLookup reported reaches the client.
+func rejectLikeAWS(response http.ResponseWriter, request *http.Request, err error) {
+	status, code, message := http.StatusForbidden, "AccessDenied", "Access Denied"
+	for _, known := range authenticationErrors {
+		if errors.Is(err, known.err) {
+			status, code, message = known.status, known.code, known.message
+			break
+		}
+	}
+
+	document, _ := xml.Marshal(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}{Code: code, Message: message})
+
+	response.Header().Set("Content-Type", "application/xml")
+	response.WriteHeader(status)
+	response.Write([]byte(xml.Header))
+	response.Write(document)
+}
+
+var authenticationErrors = []struct {
+	err     error
+	status  int
+	code    string
+	message string
+}{
+	{ErrNotSigned, http.StatusForbidden, "MissingAuthenticationToken",
+		"Request is missing Authentication Token"},
+	{ErrMalformedSignature, http.StatusBadRequest, "AuthorizationHeaderMalformed",
+		"The authorization header is malformed."},
+	{ErrUnknownAccessKey, http.StatusForbidden, "InvalidAccessKeyId",
+		"The AWS Access Key Id you provided does not exist in our records."},
+	{ErrSignatureMismatch, http.StatusForbidden, "SignatureDoesNotMatch",
+		"The request signature we calculated does not match the signature you provided."},
+	{ErrPayloadHashMismatch, http.StatusBadRequest, "XAmzContentSHA256Mismatch",
+		"The provided 'x-amz-content-sha256' header does not match what was computed."},
+	{ErrContentMD5Mismatch, http.StatusBadRequest, "BadDigest",
+		"The Content-MD5 you specified did not match what we received."},
+	{ErrRequestTimeSkewed, http.StatusForbidden, "RequestTimeTooSkewed",
+		"The difference between the request time and the current time is too large."},
+	{ErrRequestExpired, http.StatusForbidden, "AccessDenied",
+		"Request has expired"},
+	{ErrBodyRead, http.StatusBadRequest, "IncompleteBody",
+		"The request body could not be read."},
+}
@@ -0,0 +1,160 @@
+package awsauth
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/assertions/should"
+	"github.com/smartystreets/gunit"
+)
+
+func TestAuthenticatorFixture(t *testing.T) {
+	gunit.Run(new(AuthenticatorFixture), t)
+}
+
+type AuthenticatorFixture struct {
+	*gunit.Fixture
+
+	authenticator *Authenticator
+	server        *httptest.Server
+	signer        *Signer
+	accessKeyID   string
+	authenticated bool
+}
+
+func (this *AuthenticatorFixture) Setup() {
+	this.authenticator = &Authenticator{
+		Handler: http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			this.accessKeyID, this.authenticated = AccessKeyID(request.Context())
+		}),
+		Secrets: func(accessKeyID string) (string, error) {
+			if accessKeyID != testCredS3.AccessKeyID {
+				return "", errors.New("no such key")
+			}
+			return testCredS3.SecretAccessKey, nil
+		},
+	}
+	this.signer = NewSigner(Options.Clock(time.Now))
+	this.authenticator.Signer = this.signer
+	this.server = httptest.NewServer(this.authenticator)
+}
+
+func (this *AuthenticatorFixture) Teardown() {
+	this.server.Close()
+}
+
+func (this *AuthenticatorFixture) send(request *http.Request) (*http.Response, string) {
+	response, err := http.DefaultClient.Do(request)
+	this.So(err, should.BeNil)
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	return response, string(body)
+}
+
+func (this *AuthenticatorFixture) assertAuthenticated(request *http.Request) {
+	response, body := this.send(request)
+
+	this.So(response.StatusCode, should.Equal, http.StatusOK)
+	this.So(body, should.BeEmpty)
+	this.So(this.authenticated, should.BeTrue)
+	this.So(this.accessKeyID, should.Equal, testCredS3.AccessKeyID)
+}
+
+func (this *AuthenticatorFixture) TestVersion4Header() {
+	request, _ := http.NewRequest("POST", this.server.URL+"/?Action=SendMessage", nil)
+	this.signer.sign4(request, *testCredS3)
+	this.assertAuthenticated(request)
+}
+
+func (this *AuthenticatorFixture) TestVersion4Query() {
+	request, _ := http.NewRequest("GET", this.server.URL+"/bucket/object", nil)
+	this.signer.presignV4(request, time.Minute, *testCredS3)
+	this.assertAuthenticated(request)
+}
+
+func (this *AuthenticatorFixture) TestVersion2() {
+	request, _ := http.NewRequest("GET", this.server.URL+"/?Action=ListDomains", nil)
+	this.signer.sign2(request, *testCredS3)
+	this.assertAuthenticated(request)
+}
+
+func (this *AuthenticatorFixture) TestS3Header() {
+	request, _ := http.NewRequest("GET", this.server.URL+"/bucket/object", nil)
+	this.signer.signS3(request, *testCredS3)
+	this.assertAuthenticated(request)
+}
+
+func (this *AuthenticatorFixture) TestS3Url() {
+	request, _ := http.NewRequest("GET", this.server.URL+"/bucket/object", nil)
+	signS3Url(request, time.Now().Add(time.Minute), *testCredS3)
+	this.assertAuthenticated(request)
+}
+
+func (this *AuthenticatorFixture) TestUnsignedRequestIsRejected() {
+	request, _ := http.NewRequest("GET", this.server.URL+"/bucket/object", nil)
+
+	response, body := this.send(request)
+
+	this.So(response.StatusCode, should.Equal, http.StatusForbidden)
+	this.So(response.Header.Get("Content-Type"), should.Equal, "application/xml")
+	this.So(body, should.Equal, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<Error><Code>MissingAuthenticationToken</Code><Message>Request is missing Authentication Token</Message></Error>`)
+	this.So(this.authenticated, should.BeFalse)
+}
+
+func (this *AuthenticatorFixture) TestWrongSecretIsRejected() {
+	request, _ := http.NewRequest("GET", this.server.URL+"/bucket/object", nil)
+	this.signer.sign4(request, Credentials{AccessKeyID: testCredS3.AccessKeyID, SecretAccessKey: "wrong"})
+
+	response, body := this.send(request)
+
+	this.So(response.StatusCode, should.Equal, http.StatusForbidden)
+	this.So(body, should.ContainSubstring, "<Code>SignatureDoesNotMatch</Code>")
+	this.So(this.authenticated, should.BeFalse)
+}
+
+func (this *AuthenticatorFixture) TestLookupFailureIsLoggedButNotEchoed() {
+	logger := &fakeLogger{}
+	this.authenticator.Signer = NewSigner(Options.Clock(time.Now), Options.Logger(logger))
+	request, _ := http.NewRequest("GET", this.server.URL+"/bucket/object", nil)
+	this.signer.sign4(request, Credentials{AccessKeyID: "AKIDUNKNOWN", SecretAccessKey: "secret"})
+
+	response, body := this.send(request)
+
+	this.So(response.StatusCode, should.Equal, http.StatusForbidden)
+	this.So(body, should.ContainSubstring, "<Code>InvalidAccessKeyId</Code>")
+	this.So(body, should.NotContainSubstring, "no such key")
+	this.So(logger.String(), should.Equal, "awsauth: rejected GET /bucket/object: awsauth: unknown access key: no such key")
+}
+
+func (this *AuthenticatorFixture) TestMaxSkew() {
+	request, _ := http.NewRequest("GET", this.server.URL+"/bucket/object", nil)
+	NewSigner(Options.Clock(func() time.Time { return time.Now().Add(-time.Hour) })).sign4(request, *testCredS3)
+
+	response, body := this.send(request)
+
+	this.So(response.StatusCode, should.Equal, http.StatusForbidden)
+	this.So(body, should.ContainSubstring, "<Code>RequestTimeTooSkewed</Code>")
+
+	this.authenticator.Signer = NewSigner(Options.Clock(time.Now), Options.MaxSkew(2*time.Hour))
+	this.assertAuthenticated(request)
+}
+
+func (this *AuthenticatorFixture) TestCustomRejection() {
+	var rejected error
+	this.authenticator.Reject = func(response http.ResponseWriter, request *http.Request, err error) {
+		rejected = err
+		response.WriteHeader(http.StatusUnauthorized)
+	}
+	request, _ := http.NewRequest("GET", this.server.URL+"/bucket/object", nil)
+	request.Header.Set("Authorization", "Bearer token")
+
+	response, _ := this.send(request)
+
+	this.So(response.StatusCode, should.Equal, http.StatusUnauthorized)
+	this.So(rejected, should.Equal, ErrMalformedSignature)
+}
@@ -1,6 +1,7 @@
 package awsauth
 
 import (
+	"crypto/md5"
 	"encoding/base64"
 	"net/http"
 	"sort"
@@ -14,25 +15,35 @@ func signatureS3(stringToSign string, keys Credentials) string {
 	return base64.StdEncoding.EncodeToString(hashed)
 }
 
-func stringToSignS3(request *http.Request) string {
-	str := request.Method + "\n"
-
-	if request.Header.Get("Content-Md5") != "" {
-		str += request.Header.Get("Content-Md5")
-	} else {
-		body := readAndReplaceBody(request)
-		if len(body) > 0 {
-			str += hashMD5(body)
+func (this *Signer) stringToSignS3(request *http.Request) (string, error) {
+	contentMD5 := request.Header.Get("Content-Md5")
+	if contentMD5 == "" {
+		h := md5.New()
+		n, err := hashBody(request, h)
+		if err != nil {
+			return "", err
+		}
+		if n > 0 {
+			contentMD5 = base64.StdEncoding.EncodeToString(h.Sum(nil))
 		}
 	}
-	str += "\n"
+	return this.stringToSignS3WithMD5(request, contentMD5), nil
+}
 
+// stringToSignS3WithMD5 builds the string to sign with the given Content-MD5.
+func (this *Signer) stringToSignS3WithMD5(request *http.Request, contentMD5 string) string {
+	str := request.Method + "\n"
+	str += contentMD5 + "\n"
 	str += request.Header.Get("Content-Type") + "\n"
 
-	if request.Header.Get("Date") != "" {
+	switch {
+	case request.Header.Get("X-Amz-Date") != "":
+		// An X-Amz-Date header takes the place of the Date and is signed
+		// along with the other X-Amz-* headers.
+	case request.Header.Get("Date") != "":
 		str += request.Header.Get("Date")
-	} else {
-		str += timestampS3()
+	default:
+		str += this.timestampS3()
 	}
 
 	str += "\n"
@@ -81,9 +92,8 @@ func canonicalAmzHeadersS3(request *http.Request) string {
 func canonicalResourceS3(request *http.Request) string {
 	res := ""
 
-	if isS3VirtualHostedStyle(request) {
-		bucketname := strings.Split(request.Host, ".")[0]
-		res += "/" + bucketname
+	if bucket := bucketS3(request); bucket != "" {
+		res += "/" + bucket
 	}
 
 	res += request.URL.Path
@@ -97,22 +107,22 @@ func canonicalResourceS3(request *http.Request) string {
 	return res
 }
 
-func prepareRequestS3(request *http.Request) *http.Request {
-	request.Header.Set("Date", timestampS3())
+func (this *Signer) prepareRequestS3(request *http.Request) *http.Request {
+	request.Header.Set("Date", this.timestampS3())
 	if request.URL.Path == "" {
 		request.URL.Path += "/"
 	}
 	return request
 }
 
+// bucketS3 returns the bucket named in the host of a virtual-hosted-style request.
 // Info: http://docs.aws.amazon.com/AmazonS3/latest/dev/VirtualHosting.html
-func isS3VirtualHostedStyle(request *http.Request) bool {
-	service, _ := serviceAndRegion(request.Host)
-	return service == "s3" && strings.Count(request.Host, ".") == 3
+func bucketS3(request *http.Request) string {
+	return ResolveEndpoint(request.Host).Bucket
 }
 
-func timestampS3() string {
-	return now().Format(timeFormatS3)
+func (this *Signer) timestampS3() string {
+	return this.now().Format(timeFormatS3)
 }
 
 const (
@@ -19,28 +19,27 @@ import (
 // (but signed URL requests still utilize a lot of the same functionality)
 
 func TestSignatureS3Fixture(t *testing.T) {
-	gunit.RunSequential(new(SignatureS3Fixture), t)
+	gunit.Run(new(SignatureS3Fixture), t)
 }
 
 type SignatureS3Fixture struct {
 	*gunit.Fixture
 
-	keys    Credentials
-	request *http.Request
+	keys      Credentials
+	request   *http.Request
+	timestamp time.Time
+	signer    *Signer
 }
 
 func (this *SignatureS3Fixture) Setup() {
 	this.keys = *testCredS3
 	this.request = test_plainRequestS3()
-
-	now = func() time.Time {
-		parsed, _ := time.Parse(timeFormatS3, exampleReqTsS3)
-		return parsed
-	}
+	this.timestamp, _ = time.Parse(timeFormatS3, exampleReqTsS3)
+	this.signer = NewSigner(Options.Clock(func() time.Time { return this.timestamp }))
 }
 
 func (this *SignatureS3Fixture) TestRequestShouldHaveADateHeader() {
-	prepareRequestS3(this.request)
+	this.signer.prepareRequestS3(this.request)
 	this.So(this.request.Header.Get("Date"), should.Equal, exampleReqTsS3)
 }
 
@@ -56,12 +55,14 @@ func (this *SignatureS3Fixture) TestCanonicalizedResourceBuiltProperly() {
 }
 
 func (this *SignatureS3Fixture) TestStringToSignShouldBeCorrect() {
-	actual := stringToSignS3(this.request)
+	actual, err := this.signer.stringToSignS3(this.request)
+	this.So(err, should.BeNil)
 	this.So(actual, should.Equal, expectedStringToSignS3)
 }
 
 func (this *SignatureS3Fixture) TestFinalSignatureShouldBeExactlyCorrect() {
-	actual := signatureS3(stringToSignS3(this.request), this.keys)
+	stringToSign, _ := this.signer.stringToSignS3(this.request)
+	actual := signatureS3(stringToSign, this.keys)
 	this.So(actual, should.Equal, "bWq2s1WEIj+Ydj0vQ697zp+IXMU=")
 }
 
@@ -69,7 +70,7 @@ func (this *SignatureS3Fixture) TestQueryStringAuthentication() {
 	this.request = httptest.NewRequest("GET", "https://johnsmith.s3.amazonaws.com/johnsmith/photos/puppy.jpg", nil)
 
 	// The string to sign should be correct
-	actual := stringToSignS3Url("GET", now(), this.request.URL.Path)
+	actual := stringToSignS3Url("GET", this.timestamp, this.request.URL.Path)
 	this.So(actual, should.Equal, expectedStringToSignS3Url)
 
 	// The signature of string to sign should be correct
@@ -126,8 +127,8 @@ func TestCanonical(t *testing.T) {
 	request.Header.Add("X-Amz-Meta-Author", "foo@bar.com")
 	request.Header.Add("X-Amz-Magic", "abracadabra")
 
-	if stringToSignS3(request) != expectedCanonicalString {
-		t.Errorf("----Got\n***%s***\n----Expected\n***%s***", stringToSignS3(request), expectedCanonicalString)
+	if actual, _ := defaultSigner.stringToSignS3(request); actual != expectedCanonicalString {
+		t.Errorf("----Got\n***%s***\n----Expected\n***%s***", actual, expectedCanonicalString)
 	}
 }
 
@@ -0,0 +1,156 @@
+package awsauth
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// SharedCredentialsProvider reads credentials from a profile in the shared
+// credentials file that is maintained by the AWS command line tools.
+type SharedCredentialsProvider struct {
+	// Filename is the path to the credentials file. If empty, the value of
+	// AWS_SHARED_CREDENTIALS_FILE is used, falling back to ~/.aws/credentials.
+	Filename string
+
+	// Profile is the section of the file to read. If empty, the value of
+	// AWS_PROFILE is used, falling back to "default".
+	Profile string
+
+	mutex     sync.Mutex
+	retrieved bool
+}
+
+// Retrieve reads the credentials of the configured profile.
+func (this *SharedCredentialsProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.retrieved = false
+
+	filename, err := this.filename()
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) && this.Filename == "" && os.Getenv(envSharedCredentialsFile) == "" {
+		return Credentials{}, errSharedCredentialsNotFound
+	}
+	if err != nil {
+		return Credentials{}, err
+	}
+	defer file.Close()
+
+	sections, err := parseINI(file)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("awsauth: %s: %v", filename, err)
+	}
+
+	profile := this.profile()
+	section, found := sections[profile]
+	if !found {
+		return Credentials{}, fmt.Errorf("awsauth: profile %q not found in %s", profile, filename)
+	}
+
+	credentials := Credentials{
+		AccessKeyID:     section["aws_access_key_id"],
+		SecretAccessKey: section["aws_secret_access_key"],
+		SecurityToken:   section["aws_session_token"],
+	}
+	if credentials.SecurityToken == "" {
+		credentials.SecurityToken = section["aws_security_token"]
+	}
+
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return Credentials{}, fmt.Errorf("awsauth: profile %q in %s has no credentials", profile, filename)
+	}
+
+	this.retrieved = true
+	return credentials, nil
+}
+
+// IsExpired returns true until the credentials file has been successfully read.
+func (this *SharedCredentialsProvider) IsExpired() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return !this.retrieved
+}
+
+func (this *SharedCredentialsProvider) filename() (string, error) {
+	if this.Filename != "" {
+		return this.Filename, nil
+	}
+	if filename := os.Getenv(envSharedCredentialsFile); filename != "" {
+		return filename, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errHomeDirectoryNotFound
+	}
+	return filepath.Join(home, ".aws", "credentials"), nil
+}
+
+func (this *SharedCredentialsProvider) profile() string {
+	if this.Profile != "" {
+		return this.Profile
+	}
+	if profile := os.Getenv(envProfile); profile != "" {
+		return profile
+	}
+	return "default"
+}
+
+// parseINI reads the sections of an INI document into a map of section
+// name to that section's keys and values. Blank lines and lines beginning
+// with '#' or ';' are ignored.
+func parseINI(reader io.Reader) (map[string]map[string]string, error) {
+	sections := map[string]map[string]string{}
+	var section map[string]string
+
+	scanner := bufio.NewScanner(reader)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "", strings.HasPrefix(line, "#"), strings.HasPrefix(line, ";"):
+			continue
+
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section header", number)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if sections[name] == nil {
+				sections[name] = map[string]string{}
+			}
+			section = sections[name]
+
+		default:
+			equals := strings.Index(line, "=")
+			if equals < 0 {
+				return nil, fmt.Errorf("line %d: expected key = value", number)
+			}
+			if section == nil {
+				return nil, fmt.Errorf("line %d: key outside of any section", number)
+			}
+			key := strings.ToLower(strings.TrimSpace(line[:equals]))
+			section[key] = strings.TrimSpace(line[equals+1:])
+		}
+	}
+
+	return sections, scanner.Err()
+}
+
+var (
+	errHomeDirectoryNotFound     = errors.New("awsauth: unable to locate home directory for shared credentials file")
+	errSharedCredentialsNotFound = errors.New("awsauth: shared credentials file not found")
+)
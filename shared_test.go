@@ -0,0 +1,156 @@
+package awsauth
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/smartystreets/assertions/should"
+	"github.com/smartystreets/gunit"
+)
+
+func TestSharedCredentialsFixture(t *testing.T) {
+	gunit.RunSequential(new(SharedCredentialsFixture), t)
+}
+
+type SharedCredentialsFixture struct {
+	*gunit.Fixture
+
+	directory   string
+	filename    string
+	environment map[string]string
+}
+
+func (this *SharedCredentialsFixture) Setup() {
+	this.directory, _ = ioutil.TempDir("", "awsauth")
+	this.filename = filepath.Join(this.directory, "credentials")
+	ioutil.WriteFile(this.filename, []byte(testSharedCredentialsFile), 0600)
+
+	this.environment = map[string]string{}
+	for _, name := range []string{envSharedCredentialsFile, envProfile} {
+		this.environment[name] = os.Getenv(name)
+		os.Unsetenv(name)
+	}
+}
+
+func (this *SharedCredentialsFixture) Teardown() {
+	os.RemoveAll(this.directory)
+	for name, value := range this.environment {
+		os.Setenv(name, value)
+	}
+}
+
+func (this *SharedCredentialsFixture) TestDefaultProfile() {
+	provider := &SharedCredentialsProvider{Filename: this.filename}
+
+	this.So(provider.IsExpired(), should.BeTrue)
+
+	credentials, err := provider.Retrieve(context.Background())
+
+	this.So(err, should.BeNil)
+	this.So(credentials, should.Resemble, Credentials{AccessKeyID: "default-id", SecretAccessKey: "default-secret"})
+	this.So(provider.IsExpired(), should.BeFalse)
+}
+
+func (this *SharedCredentialsFixture) TestNamedProfileWithSessionToken() {
+	provider := &SharedCredentialsProvider{Filename: this.filename, Profile: "temporary"}
+
+	credentials, err := provider.Retrieve(context.Background())
+
+	this.So(err, should.BeNil)
+	this.So(credentials, should.Resemble, Credentials{AccessKeyID: "temp-id", SecretAccessKey: "temp=secret", SecurityToken: "temp-token"})
+}
+
+func (this *SharedCredentialsFixture) TestFilenameAndProfileFromEnvironment() {
+	os.Setenv(envSharedCredentialsFile, this.filename)
+	os.Setenv(envProfile, "temporary")
+
+	credentials, err := (&SharedCredentialsProvider{}).Retrieve(context.Background())
+
+	this.So(err, should.BeNil)
+	this.So(credentials.AccessKeyID, should.Equal, "temp-id")
+}
+
+func (this *SharedCredentialsFixture) TestMissingProfile() {
+	provider := &SharedCredentialsProvider{Filename: this.filename, Profile: "missing"}
+
+	_, err := provider.Retrieve(context.Background())
+
+	this.So(err, should.NotBeNil)
+	this.So(provider.IsExpired(), should.BeTrue)
+}
+
+func (this *SharedCredentialsFixture) TestProfileWithoutKeys() {
+	_, err := (&SharedCredentialsProvider{Filename: this.filename, Profile: "empty"}).Retrieve(context.Background())
+
+	this.So(err, should.NotBeNil)
+}
+
+func (this *SharedCredentialsFixture) TestMissingFile() {
+	provider := &SharedCredentialsProvider{Filename: filepath.Join(this.directory, "missing")}
+
+	_, err := provider.Retrieve(context.Background())
+
+	this.So(err, should.NotBeNil)
+}
+
+func (this *SharedCredentialsFixture) TestConcurrentUse() {
+	provider := &SharedCredentialsProvider{Filename: this.filename}
+	environment := &EnvProvider{}
+
+	var waiter sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		waiter.Add(1)
+		go func() {
+			defer waiter.Done()
+			provider.Retrieve(context.Background())
+			provider.IsExpired()
+			environment.Retrieve(context.Background())
+			environment.IsExpired()
+		}()
+	}
+	waiter.Wait()
+
+	this.So(provider.IsExpired(), should.BeFalse)
+}
+
+func (this *SharedCredentialsFixture) TestParseINI() {
+	sections, err := parseINI(strings.NewReader(testSharedCredentialsFile))
+
+	this.So(err, should.BeNil)
+	this.So(sections["default"], should.Resemble, map[string]string{
+		"aws_access_key_id":     "default-id",
+		"aws_secret_access_key": "default-secret",
+	})
+	this.So(sections["empty"], should.Resemble, map[string]string{"region": "us-west-2"})
+}
+
+func (this *SharedCredentialsFixture) TestParseMalformedINI() {
+	_, err := parseINI(strings.NewReader("[default\n"))
+	this.So(err, should.NotBeNil)
+
+	_, err = parseINI(strings.NewReader("[default]\nno equals sign\n"))
+	this.So(err, should.NotBeNil)
+
+	_, err = parseINI(strings.NewReader("key = value\n"))
+	this.So(err, should.NotBeNil)
+}
+
+const testSharedCredentialsFile = `# Comments are ignored
+[default]
+aws_access_key_id = default-id
+aws_secret_access_key = default-secret
+
+; so are these
+[ temporary ]
+AWS_ACCESS_KEY_ID=temp-id
+aws_secret_access_key=temp=secret
+aws_session_token   =   temp-token
+
+[empty]
+region = us-west-2
+`
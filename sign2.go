@@ -2,35 +2,119 @@ package awsauth
 
 import (
 	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 )
 
-func prepareRequestV2(request *http.Request, keys Credentials) *http.Request {
+func (this *Signer) prepareRequestV2(request *http.Request, keys Credentials) *http.Request {
+	augmentRequestQuery(request, this.authParametersV2(keys))
 
-	keyID := keys.AccessKeyID
+	if request.URL.Path == "" {
+		request.URL.Path += "/"
+	}
+
+	return request
+}
 
+// authParametersV2 returns the parameters that identify the credentials
+// and signing method of a request.
+func (this *Signer) authParametersV2(keys Credentials) url.Values {
 	values := url.Values{}
-	values.Set("AWSAccessKeyId", keyID)
+	values.Set("AWSAccessKeyId", keys.AccessKeyID)
 	values.Set("SignatureVersion", "2")
 	values.Set("SignatureMethod", "HmacSHA256")
-	values.Set("Timestamp", timestampV2())
+	values.Set("Timestamp", this.timestampV2())
+	return values
+}
 
-	augmentRequestQuery(request, values)
+// signFormV2 signs a POST request whose parameters are in its
+// application/x-www-form-urlencoded body. Parameters in the query string
+// are moved into the body, since AWS only reads one or the other, and the
+// authentication parameters and the signature are added to it as well.
+// The body is encoded anew.
+func (this *Signer) signFormV2(request *http.Request, keys Credentials) error {
+	body, err := readAndReplaceBody(request)
+	if err != nil {
+		return err
+	}
+
+	parameters := parseQuery(string(body))
+	for key, values := range parseQuery(request.URL.RawQuery) {
+		parameters[key] = append(parameters[key], values...)
+	}
+	request.URL.RawQuery = ""
+	for key, values := range this.authParametersV2(keys) {
+		parameters[key] = values
+	}
+	if keys.SecurityToken != "" {
+		parameters.Set("SecurityToken", keys.SecurityToken)
+	}
 
 	if request.URL.Path == "" {
 		request.URL.Path += "/"
 	}
 
-	return request
+	stringToSign := stringToSignWithParametersV2(request, parameters)
+	parameters.Set("Signature", signatureV2(stringToSign, keys))
+
+	replaceBodyV2(request, normquery(parameters))
+	return nil
+}
+
+// replaceBodyV2 makes the encoded parameters the body of the request.
+func replaceBodyV2(request *http.Request, encoded string) {
+	request.Body = ioutil.NopCloser(strings.NewReader(encoded))
+	request.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(encoded)), nil
+	}
+	request.ContentLength = int64(len(encoded))
+	if request.Header.Get("Content-Length") != "" {
+		request.Header.Set("Content-Length", strconv.Itoa(len(encoded)))
+	}
+}
+
+// isFormV2 reports whether the parameters of the request are in an
+// application/x-www-form-urlencoded body rather than its query string.
+func isFormV2(request *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	return request.Method == "POST" && mediaType == "application/x-www-form-urlencoded"
+}
+
+// parametersV2 returns the parameters of a received request: those of its
+// query string and, if it is a form, those of its body, which are all signed,
+// so that none can be slipped in beside the signed ones. A body that is read
+// is replaced so that the handler can read it again.
+func parametersV2(request *http.Request) (url.Values, error) {
+	parameters := parseQuery(request.URL.RawQuery)
+	if !isFormV2(request) {
+		return parameters, nil
+	}
+	body, err := readAndReplaceBody(request)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range parseQuery(string(body)) {
+		parameters[key] = append(parameters[key], values...)
+	}
+	return parameters, nil
 }
 
 func stringToSignV2(request *http.Request) string {
+	return stringToSignWithParametersV2(request, parseQuery(request.URL.RawQuery))
+}
+
+// stringToSignWithParametersV2 builds the string to sign from the given
+// parameters of the request, less any signature among them.
+func stringToSignWithParametersV2(request *http.Request, parameters url.Values) string {
 	str := request.Method + "\n"
 	str += strings.ToLower(request.URL.Host) + "\n"
 	str += request.URL.Path + "\n"
-	str += canonicalQueryStringV2(request)
+	str += canonicalParametersV2(parameters)
 	return str
 }
 
@@ -39,12 +123,24 @@ func signatureV2(strToSign string, keys Credentials) string {
 	return base64.StdEncoding.EncodeToString(hashed)
 }
 
+// canonicalQueryStringV2 returns the query string of the request, less any
+// signature, in canonical form.
 func canonicalQueryStringV2(request *http.Request) string {
-	return request.URL.RawQuery
+	return canonicalParametersV2(parseQuery(request.URL.RawQuery))
+}
+
+func canonicalParametersV2(parameters url.Values) string {
+	unsigned := url.Values{}
+	for key, values := range parameters {
+		if key != "Signature" {
+			unsigned[key] = values
+		}
+	}
+	return normquery(unsigned)
 }
 
-func timestampV2() string {
-	return now().Format(timeFormatV2)
+func (this *Signer) timestampV2() string {
+	return this.now().Format(timeFormatV2)
 }
 
 const timeFormatV2 = "2006-01-02T15:04:05"
@@ -5,7 +5,6 @@ package awsauth
 import (
 	"encoding/base64"
 	"net/http"
-	"time"
 )
 
 func stringToSignV3(request *http.Request) string {
@@ -29,8 +28,8 @@ func buildAuthHeaderV3(signature string, keys Credentials) string {
 		", Signature=" + signature
 }
 
-func prepareRequestV3(request *http.Request) *http.Request {
-	ts := timestampV3()
+func (this *Signer) prepareRequestV3(request *http.Request) *http.Request {
+	ts := this.timestampV3()
 	necessaryDefaults := map[string]string{
 		"Content-Type": "application/x-www-form-urlencoded; charset=utf-8",
 		"x-amz-date":   ts,
@@ -51,8 +50,9 @@ func prepareRequestV3(request *http.Request) *http.Request {
 	return request
 }
 
-func timestampV3() string {
-	return now().Format(timeFormatV3)
+func (this *Signer) timestampV3() string {
+	return this.now().Format(timeFormatV3)
 }
 
-const timeFormatV3 = time.RFC1123
+// timeFormatV3 is the HTTP date format, which always names the zone GMT.
+const timeFormatV3 = http.TimeFormat
@@ -19,18 +19,16 @@ func TestSignature3(t *testing.T) {
 	// Given bogus credentials
 	keys := *testCredV3
 
-	// Mock time
-	now = func() time.Time {
-		parsed, _ := time.Parse(timeFormatV3, exampleReqTsV3)
-		return parsed
-	}
+	// At the time of the example
+	clock, _ := time.Parse(timeFormatV3, exampleReqTsV3)
+	signer := NewSigner(Options.Clock(func() time.Time { return clock }))
 
 	// Given a plain request that is unprepared
 	request := test_plainRequestV3()
 
 	// The request should be prepared to be signed
 	expectedUnsigned := test_unsignedRequestV3()
-	prepareRequestV3(request)
+	signer.prepareRequestV3(request)
 	assert.So(request, should.Resemble, expectedUnsigned)
 
 	// Given a prepared, but unsigned, request
@@ -46,7 +44,7 @@ func TestSignature3(t *testing.T) {
 	assert.So(signatureV3(stringToSignV3(request), keys), should.Equal, "PjAJ6buiV6l4WyzmmuwtKE59NJXVg5Dr3Sn4PCMZ0Yk=")
 
 	// The final signed request should be correctly formed
-	Sign3(request, keys)
+	signer.sign3(request, keys)
 	assert.So(request.Header.Get("X-Amzn-Authorization"), should.Resemble, expectedAuthHeaderV3)
 }
 
@@ -1,26 +1,116 @@
 package awsauth
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"net/http"
+	"net/url"
+	"path"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
-func hashedCanonicalRequestV4(request *http.Request, meta *metadata) string {
+func (this *Signer) hashedCanonicalRequestV4(request *http.Request, meta *metadata) (string, error) {
 	// TASK 1. http://docs.aws.amazon.com/general/latest/gr/sigv4-create-canonical-request.html
 
-	payload := readAndReplaceBody(request)
-	payloadHash := hashSHA256(payload)
-	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	payloadHash, err := this.payloadHashV4(request)
+	if err != nil {
+		return "", err
+	}
+	if request.Header.Get("X-Amz-Content-Sha256") != payloadHash {
+		request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+		rememberPayloadHashV4(request, payloadHash)
+	}
 
 	// Set this in header values to make it appear in the range of headers to sign
 	request.Header.Set("Host", request.Host)
 
+	signedHeaders := this.headersToSignV4(request)
+	meta.signedHeaders = concat(";", signedHeaders...)
+	service, _ := this.serviceAndRegion(request, request.Host)
+	canonicalRequest := canonicalRequestV4(request, service, parseQuery(request.URL.RawQuery), signedHeaders, payloadHash)
+	this.logf("awsauth: canonical request:\n%s", canonicalRequest)
+
+	return hashSHA256([]byte(canonicalRequest)), nil
+}
+
+// payloadHashV4 returns the hex-encoded SHA-256 hash of the request body.
+// A hash attached to the request context with WithPayloadHash or
+// WithUnsignedPayload, or else one the caller put in the X-Amz-Content-Sha256
+// header, is used as is, so that large bodies whose hash is known need not be
+// read at all; a hash the Signer itself put in the header when it signed the
+// request before is not. Failing those, a Signer configured for UnsignedPayload
+// doesn't read the body either.
+func (this *Signer) payloadHashV4(request *http.Request) (string, error) {
+	if precomputed, ok := request.Context().Value(payloadHashKey{}).(string); ok {
+		return precomputed, nil
+	}
+	if precomputed := request.Header.Get("X-Amz-Content-Sha256"); precomputed != "" && !wrotePayloadHashV4(request, precomputed) {
+		return precomputed, nil
+	}
+	if this.payload == UnsignedPayload {
+		return unsignedPayloadV4, nil
+	}
+
+	h := sha256.New()
+	if _, err := hashBody(request, h); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// rememberPayloadHashV4 notes in the context of the request that the payload
+// hash in its header was written by the Signer rather than the caller.
+func rememberPayloadHashV4(request *http.Request, payloadHash string) {
+	ctx := context.WithValue(request.Context(), writtenPayloadHashKey{}, payloadHash)
+	*request = *request.WithContext(ctx)
+}
+
+// wrotePayloadHashV4 reports whether the payload hash in the header of the
+// request is the one the Signer wrote when it signed the request before. It is
+// calculated anew in case the body has changed since.
+func wrotePayloadHashV4(request *http.Request, payloadHash string) bool {
+	written, ok := request.Context().Value(writtenPayloadHashKey{}).(string)
+	return ok && written == payloadHash
+}
+
+// WithUnsignedPayload returns a copy of the context that makes Version 4
+// signing of a request carrying it use UNSIGNED-PAYLOAD in place of the hash
+// of the body, which S3 accepts, so the body is never read while signing.
+func WithUnsignedPayload(ctx context.Context) context.Context {
+	return context.WithValue(ctx, payloadHashKey{}, unsignedPayloadV4)
+}
+
+// WithPayloadHash returns a copy of the context that makes Version 4 signing
+// of a request carrying it use the given hex-encoded SHA-256 hash of the body
+// instead of reading the body to calculate it.
+func WithPayloadHash(ctx context.Context, hash string) context.Context {
+	return context.WithValue(ctx, payloadHashKey{}, strings.ToLower(hash))
+}
+
+type payloadHashKey struct{}
+
+type writtenPayloadHashKey struct{}
+
+// headersToSignV4 chooses the headers of the request to include in the
+// signature and returns their lower-cased names in sorted order.
+func (this *Signer) headersToSignV4(request *http.Request) []string {
+	if length, ok := contentLengthV4(request); ok && this.signed["Content-Length"] {
+		// Set this in header values to make it appear in the range of headers to sign
+		request.Header.Set("Content-Length", length)
+	}
+
 	var sortedHeaderKeys []string
-	for key, _ := range request.Header {
-		switch key {
-		case "Content-Type", "Content-Md5", "Host":
+	for key := range request.Header {
+		switch {
+		case key == "Host":
+		case this.unsigned[key], unsignableHeadersV4[key]:
+			continue
+		case key == "Content-Type", key == "Content-Md5", this.signed[key]:
 		default:
 			if !strings.HasPrefix(key, "X-Amz-") {
 				continue
@@ -29,10 +119,48 @@ func hashedCanonicalRequestV4(request *http.Request, meta *metadata) string {
 		sortedHeaderKeys = append(sortedHeaderKeys, strings.ToLower(key))
 	}
 	sort.Strings(sortedHeaderKeys)
+	return sortedHeaderKeys
+}
+
+// contentLengthV4 returns the Content-Length header that net/http will
+// send with the request, if any.
+func contentLengthV4(request *http.Request) (string, bool) {
+	if length := request.Header.Get("Content-Length"); length != "" {
+		return length, true
+	}
+	switch {
+	case request.ContentLength > 0:
+	case request.ContentLength == 0 && (request.Method == "POST" || request.Method == "PUT" || request.Method == "PATCH"):
+	default:
+		return "", false
+	}
+	return strconv.FormatInt(request.ContentLength, 10), true
+}
 
+// unsignableHeadersV4 are never signed, even when asked to, because they are
+// changed or dropped on the way to AWS by clients, proxies and load balancers.
+var unsignableHeadersV4 = map[string]bool{
+	"Authorization":       true,
+	"Connection":          true,
+	"Expect":              true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+	"User-Agent":          true,
+	"X-Amzn-Trace-Id":     true,
+}
+
+// canonicalRequestV4 assembles the canonical request for the service from the
+// given query parameters, the named (lower-case, sorted) headers and the payload hash.
+func canonicalRequestV4(request *http.Request, service string, query url.Values, signedHeaders []string, payloadHash string) string {
 	var headersToSign string
-	for _, key := range sortedHeaderKeys {
-		value := strings.TrimSpace(request.Header.Get(key))
+	for _, key := range signedHeaders {
+		value := canonicalHeaderValueV4(request.Header.Values(key))
 		if key == "host" {
 			//AWS does not include port in signing request.
 			if strings.Contains(value, ":") {
@@ -45,23 +173,158 @@ func hashedCanonicalRequestV4(request *http.Request, meta *metadata) string {
 		}
 		headersToSign += key + ":" + value + "\n"
 	}
-	meta.signedHeaders = concat(";", sortedHeaderKeys...)
-	canonicalRequest := concat("\n", request.Method, normuri(request.URL.Path), normquery(request.URL.Query()), headersToSign, meta.signedHeaders, payloadHash)
 
-	return hashSHA256([]byte(canonicalRequest))
+	return concat("\n", request.Method, canonicalURIV4(request.URL, service), normquery(query), headersToSign, concat(";", signedHeaders...), payloadHash)
+}
+
+// canonicalURIV4 returns the path of the request, as it is sent, in the form
+// the service signs it. S3 takes the path as it is, with each segment encoded
+// once. Every other service removes dot segments and empty segments from the
+// path and encodes what's left again, so escapes in it are encoded twice.
+func canonicalURIV4(u *url.URL, service string) string {
+	requestPath := requestPathV4(u)
+	if singleEncodedServicesV4[service] {
+		return normuri(requestPath)
+	}
+
+	normalized := path.Clean(requestPath)
+	if normalized != "/" && (strings.HasSuffix(requestPath, "/") || strings.HasSuffix(requestPath, "/.") || strings.HasSuffix(requestPath, "/..")) {
+		normalized += "/"
+	}
+	return encodePath(normalized, false)
+}
+
+// requestPathV4 returns the path of the URL as net/http sends it: the
+// opaque part if there is one, or else the path, escaped as in RawPath
+// when that is a valid encoding of it.
+func requestPathV4(u *url.URL) string {
+	requestPath := u.EscapedPath()
+	if u.Opaque != "" {
+		requestPath = u.Opaque
+		if strings.HasPrefix(requestPath, "//") {
+			// An opaque "//host/path" is sent as an absolute URL; only its path is signed.
+			requestPath = requestPath[2:]
+			if i := strings.IndexByte(requestPath, '/'); i >= 0 {
+				requestPath = requestPath[i:]
+			} else {
+				requestPath = ""
+			}
+		}
+	}
+	if requestPath == "" {
+		return "/"
+	}
+	return requestPath
+}
+
+// singleEncodedServicesV4 are the services that sign the path of a request
+// without normalizing it or encoding it a second time.
+var singleEncodedServicesV4 = map[string]bool{
+	"s3":               true,
+	"s3-object-lambda": true,
+	"s3-outposts":      true,
+	"s3express":        true,
 }
 
-func stringToSignV4(request *http.Request, hashedCanonReq string, meta *metadata) string {
+// canonicalHeaderValueV4 joins the values of a header given more than once
+// with commas, each trimmed and with runs of spaces collapsed into one.
+func canonicalHeaderValueV4(values []string) string {
+	canonical := make([]string, len(values))
+	for i, value := range values {
+		canonical[i] = strings.Join(strings.Fields(value), " ")
+	}
+	return strings.Join(canonical, ",")
+}
+
+func (this *Signer) stringToSignV4(request *http.Request, hashedCanonReq string, meta *metadata) string {
 	// TASK 2. http://docs.aws.amazon.com/general/latest/gr/sigv4-create-string-to-sign.html
 
 	requestTs := request.Header.Get("X-Amz-Date")
+	this.scopeV4(request, requestTs, meta)
+	return this.joinStringToSignV4(requestTs, hashedCanonReq, meta)
+}
 
+// joinStringToSignV4 puts together the string to sign from the scope
+// already filled in the metadata.
+func (this *Signer) joinStringToSignV4(requestTs, hashedCanonReq string, meta *metadata) string {
+	stringToSign := concat("\n", meta.algorithm, requestTs, meta.credentialScope, hashedCanonReq)
+	this.logf("awsauth: string to sign:\n%s", stringToSign)
+	return stringToSign
+}
+
+// scopeV4 fills in the algorithm and credential scope of a request
+// signed at the given timestamp.
+func (this *Signer) scopeV4(request *http.Request, requestTs string, meta *metadata) {
 	meta.algorithm = "AWS4-HMAC-SHA256"
-	meta.service, meta.region = serviceAndRegion(request.Host)
+	meta.service, meta.region = this.serviceAndRegion(request, request.Host)
 	meta.date = tsDateV4(requestTs)
 	meta.credentialScope = concat("/", meta.date, meta.region, meta.service, "aws4_request")
+}
+
+// presignV4 adds the authentication parameters and the signature to the
+// query string of the request instead of its headers.
+// http://docs.aws.amazon.com/general/latest/gr/sigv4-add-signature-to-request.html#sigv4-add-signature-querystring
+func (this *Signer) presignV4(request *http.Request, expires time.Duration, keys Credentials) error {
+	meta := new(metadata)
+	requestTs := this.timestampV4()
+	this.scopeV4(request, requestTs, meta)
+
+	query, stringToSign, err := this.presignedStringToSignV4(request, parseQuery(request.URL.RawQuery), expires, keys, requestTs, meta)
+	if err != nil {
+		return err
+	}
+
+	signingKey := signingKeyV4(keys.SecretAccessKey, meta.date, meta.region, meta.service)
+	signature := signatureV4(signingKey, stringToSign)
+
+	request.URL.RawQuery = normquery(query) + "&X-Amz-Signature=" + signature
+
+	return nil
+}
+
+// presignedStringToSignV4 adds the authentication parameters to the query
+// of a presigned request and returns it, less the signature, along with the
+// string to sign for it. The scope must already be filled in the metadata.
+func (this *Signer) presignedStringToSignV4(request *http.Request, query url.Values, expires time.Duration, keys Credentials, requestTs string, meta *metadata) (url.Values, string, error) {
+	if expires < time.Second || expires > maxExpiresV4 {
+		return nil, "", errPresignExpiresV4
+	}
+
+	if request.URL.Path == "" {
+		request.URL.Path += "/"
+	}
+
+	// S3 allows the body of a presigned upload to be supplied by whoever
+	// performs the request; every other service signs the payload.
+	payloadHash := unsignedPayloadV4
+	if meta.service != "s3" {
+		var err error
+		if payloadHash, err = this.payloadHashV4(request); err != nil {
+			return nil, "", err
+		}
+	}
 
-	return concat("\n", meta.algorithm, requestTs, meta.credentialScope, hashedCanonReq)
+	// Set this in header values to make it appear in the range of headers to sign
+	request.Header.Set("Host", request.Host)
+
+	signedHeaders := this.headersToSignV4(request)
+	meta.signedHeaders = concat(";", signedHeaders...)
+
+	query.Del("X-Amz-Signature")
+	query.Set("X-Amz-Algorithm", meta.algorithm)
+	query.Set("X-Amz-Credential", keys.AccessKeyID+"/"+meta.credentialScope)
+	query.Set("X-Amz-Date", requestTs)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires/time.Second)))
+	query.Set("X-Amz-SignedHeaders", meta.signedHeaders)
+	if keys.SecurityToken != "" {
+		query.Set("X-Amz-Security-Token", keys.SecurityToken)
+	}
+
+	canonicalRequest := canonicalRequestV4(request, meta.service, query, signedHeaders, payloadHash)
+	this.logf("awsauth: canonical request:\n%s", canonicalRequest)
+	stringToSign := this.joinStringToSignV4(requestTs, hashSHA256([]byte(canonicalRequest)), meta)
+
+	return query, stringToSign, nil
 }
 
 func signatureV4(signingKey []byte, stringToSign string) string {
@@ -70,10 +333,10 @@ func signatureV4(signingKey []byte, stringToSign string) string {
 	return hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
 }
 
-func prepareRequestV4(request *http.Request) *http.Request {
+func (this *Signer) prepareRequestV4(request *http.Request) *http.Request {
 	necessaryDefaults := map[string]string{
 		"Content-Type": "application/x-www-form-urlencoded; charset=utf-8",
-		"X-Amz-Date":   timestampV4(),
+		"X-Amz-Date":   this.timestampV4(),
 	}
 
 	for header, value := range necessaryDefaults {
@@ -106,12 +369,18 @@ func buildAuthHeaderV4(signature string, meta *metadata, keys Credentials) strin
 		", Signature=" + signature
 }
 
-func timestampV4() string {
-	return now().Format(timeFormatV4)
+func (this *Signer) timestampV4() string {
+	return this.now().Format(timeFormatV4)
 }
 
 func tsDateV4(timestamp string) string {
 	return timestamp[:8]
 }
 
-const timeFormatV4 = "20060102T150405Z"
+const (
+	timeFormatV4      = "20060102T150405Z"
+	unsignedPayloadV4 = "UNSIGNED-PAYLOAD"
+	maxExpiresV4      = 7 * 24 * time.Hour
+)
+
+var errPresignExpiresV4 = errors.New("awsauth: presigned requests must expire between one second and seven days from now")
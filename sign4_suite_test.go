@@ -0,0 +1,123 @@
+package awsauth
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/assertions"
+	"github.com/smartystreets/assertions/should"
+)
+
+// TestSignatureV4TestSuite signs each request of the AWS test suite and
+// compares the canonical request, string to sign and Authorization header
+// with those published for it.
+func TestSignatureV4TestSuite(t *testing.T) {
+	root := filepath.Join("testdata", "aws-sig-v4-test-suite")
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || filepath.Ext(path) != ".req" {
+			return err
+		}
+		name := strings.TrimSuffix(path, ".req")
+		t.Run(filepath.Base(name), func(t *testing.T) {
+			test_suiteCaseV4(t, name)
+		})
+		return nil
+	})
+}
+
+func test_suiteCaseV4(t *testing.T, name string) {
+	assert := assertions.New(t)
+	request, headers := test_readSuiteRequestV4(t, name+".req")
+
+	// The suite signs every header of the request and nothing else.
+	var ignored []string
+	for _, header := range []string{"Content-Type", "X-Amz-Content-Sha256"} {
+		if !headers[header] {
+			ignored = append(ignored, header)
+		}
+	}
+	var signed []string
+	for header := range headers {
+		signed = append(signed, header)
+	}
+
+	clock, _ := time.Parse(timeFormatV4, "20150830T123600Z")
+	logger := &fakeLogger{}
+	signer := NewSigner(
+		Options.Clock(func() time.Time { return clock }),
+		Options.Credentials(NewStaticProvider(testCredSuiteV4)),
+		Options.Region("us-east-1"),
+		Options.Service("service"),
+		Options.SignHeaders(signed...),
+		Options.IgnoreHeaders(ignored...),
+		Options.Logger(logger),
+	)
+
+	assert.So(signer.Sign(request), should.BeNil)
+
+	logged := strings.SplitN(strings.TrimPrefix(logger.String(), "awsauth: canonical request:\n"), "\nawsauth: string to sign:\n", 2)
+	assert.So(logged, should.HaveLength, 2)
+	assert.So(logged[0], should.Equal, test_readSuiteFile(t, name+".creq"))
+	assert.So(logged[1], should.Equal, test_readSuiteFile(t, name+".sts"))
+	assert.So(request.Header.Get("Authorization"), should.Equal, test_readSuiteFile(t, name+".authz"))
+}
+
+// test_readSuiteRequestV4 parses a request of the test suite, whose request
+// line and headers are taken as they are, and returns it along with the
+// canonical names of the headers other than Host.
+func test_readSuiteRequestV4(t *testing.T, path string) (*http.Request, map[string]bool) {
+	lines := strings.Split(test_readSuiteFile(t, path), "\n")
+
+	fields := strings.Fields(lines[0])
+	method, target := fields[0], strings.TrimSuffix(strings.TrimPrefix(lines[0], fields[0]+" "), " HTTP/1.1")
+	path, query := target, ""
+	if i := strings.Index(target, "?"); i >= 0 {
+		path, query = target[:i], target[i+1:]
+	}
+	request, err := http.NewRequest(method, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.URL = &url.URL{Scheme: "https", Host: "example.amazonaws.com", Path: path, RawQuery: query}
+	if request.URL.EscapedPath() != path {
+		// Send paths with spaces or UTF-8 in them without escaping them, as in the request line.
+		request.URL.Opaque = path
+	}
+
+	headers := map[string]bool{}
+	for i, line := range lines[1:] {
+		if line == "" {
+			body := strings.Join(lines[i+2:], "\n")
+			request.Body = ioutil.NopCloser(strings.NewReader(body))
+			request.ContentLength = int64(len(body))
+			break
+		}
+		pair := strings.SplitN(line, ":", 2)
+		if http.CanonicalHeaderKey(pair[0]) == "Host" {
+			request.Host = pair[1]
+			continue
+		}
+		request.Header.Add(pair[0], pair[1])
+		headers[http.CanonicalHeaderKey(pair[0])] = true
+	}
+	return request, headers
+}
+
+func test_readSuiteFile(t *testing.T, path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+var testCredSuiteV4 = Credentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
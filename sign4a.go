@@ -0,0 +1,152 @@
+package awsauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// sign4a signs a request with Signature Version 4a, whose signature is valid
+// in every region of the set in the X-Amz-Region-Set header rather than one.
+// The canonical request is that of Version 4; the string to sign is signed
+// with an ECDSA key derived from the secret access key.
+func (this *Signer) sign4a(request *http.Request, keys Credentials) error {
+	key, err := deriveKeyV4a(keys)
+	if err != nil {
+		return err
+	}
+
+	// Add the X-Amz-Security-Token header when using STS
+	if keys.SecurityToken != "" {
+		request.Header.Set("X-Amz-Security-Token", keys.SecurityToken)
+	}
+
+	this.prepareRequestV4(request)
+	request.Header.Set("X-Amz-Region-Set", this.regionSetV4a(request))
+	meta := new(metadata)
+
+	hashedCanonReq, err := this.hashedCanonicalRequestV4(request, meta)
+	if err != nil {
+		return err
+	}
+
+	requestTs := request.Header.Get("X-Amz-Date")
+	this.scopeV4a(request, requestTs, meta)
+	stringToSign := this.joinStringToSignV4(requestTs, hashedCanonReq, meta)
+
+	signature, err := signatureV4a(key, stringToSign)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Authorization", buildAuthHeaderV4(signature, meta, keys))
+
+	return nil
+}
+
+// presignV4a adds the authentication parameters, the region set and the
+// Version 4a signature to the query string of the request.
+func (this *Signer) presignV4a(request *http.Request, expires time.Duration, keys Credentials) error {
+	key, err := deriveKeyV4a(keys)
+	if err != nil {
+		return err
+	}
+
+	meta := new(metadata)
+	requestTs := this.timestampV4()
+	this.scopeV4a(request, requestTs, meta)
+
+	query := parseQuery(request.URL.RawQuery)
+	query.Set("X-Amz-Region-Set", this.regionSetV4a(request))
+
+	query, stringToSign, err := this.presignedStringToSignV4(request, query, expires, keys, requestTs, meta)
+	if err != nil {
+		return err
+	}
+
+	signature, err := signatureV4a(key, stringToSign)
+	if err != nil {
+		return err
+	}
+
+	request.URL.RawQuery = normquery(query) + "&X-Amz-Signature=" + signature
+
+	return nil
+}
+
+// scopeV4a fills in the algorithm and credential scope of a request signed
+// with Version 4a at the given timestamp. The scope names no region; the
+// regions are in the region set instead.
+func (this *Signer) scopeV4a(request *http.Request, requestTs string, meta *metadata) {
+	meta.algorithm = "AWS4-ECDSA-P256-SHA256"
+	meta.service, meta.region = this.serviceAndRegion(request, request.Host)
+	meta.date = tsDateV4(requestTs)
+	meta.credentialScope = concat("/", meta.date, meta.service, "aws4_request")
+}
+
+// regionSetV4a returns the comma-separated regions the request is signed for:
+// those already in its X-Amz-Region-Set header, those the Signer was given,
+// or else the region of the request alone.
+func (this *Signer) regionSetV4a(request *http.Request) string {
+	if regionSet := request.Header.Get("X-Amz-Region-Set"); regionSet != "" {
+		return regionSet
+	}
+	if len(this.regionSet) > 0 {
+		return strings.Join(this.regionSet, ",")
+	}
+	_, region := this.serviceAndRegion(request, request.Host)
+	return region
+}
+
+// signatureV4a signs the SHA-256 hash of the string to sign and returns the
+// hex-encoded, ASN.1 DER-encoded ECDSA signature.
+func signatureV4a(key *ecdsa.PrivateKey, stringToSign string) (string, error) {
+	digest := sha256.Sum256([]byte(stringToSign))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(signature), nil
+}
+
+// deriveKeyV4a derives the P-256 private key of the credentials from the
+// access key pair with the HMAC-SHA256 counter-mode KDF of NIST SP 800-108,
+// trying successive counters until the candidate is no greater than n-2,
+// so that the private key (the candidate plus one) lies in [1, n-1].
+func deriveKeyV4a(keys Credentials) (*ecdsa.PrivateKey, error) {
+	curve := elliptic.P256()
+	nMinusTwo := new(big.Int).Sub(curve.Params().N, big.NewInt(2))
+
+	for counter := 1; counter <= 0xff; counter++ {
+		mac := hmac.New(sha256.New, []byte("AWS4A"+keys.SecretAccessKey))
+		binary.Write(mac, binary.BigEndian, uint32(1))
+		mac.Write([]byte("AWS4-ECDSA-P256-SHA256"))
+		mac.Write([]byte{0x00})
+		mac.Write([]byte(keys.AccessKeyID))
+		mac.Write([]byte{byte(counter)})
+		binary.Write(mac, binary.BigEndian, uint32(curve.Params().BitSize))
+
+		candidate := new(big.Int).SetBytes(mac.Sum(nil))
+		if candidate.Cmp(nMinusTwo) > 0 {
+			continue
+		}
+
+		key := new(ecdsa.PrivateKey)
+		key.Curve = curve
+		key.D = candidate.Add(candidate, big.NewInt(1))
+		key.X, key.Y = curve.ScalarBaseMult(key.D.Bytes())
+		return key, nil
+	}
+	return nil, errDeriveKeyV4a
+}
+
+var errDeriveKeyV4a = errors.New("awsauth: could not derive a Signature Version 4a key from the credentials")
@@ -0,0 +1,139 @@
+package awsauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/assertions/should"
+	"github.com/smartystreets/gunit"
+)
+
+func TestSign4aFixture(t *testing.T) {
+	gunit.Run(new(Sign4aFixture), t)
+}
+
+type Sign4aFixture struct {
+	*gunit.Fixture
+
+	logger *fakeLogger
+	signer *Signer
+}
+
+func (this *Sign4aFixture) Setup() {
+	clock, _ := time.Parse(timeFormatV4, "20150830T123600Z")
+	this.logger = &fakeLogger{}
+	this.signer = NewSigner(
+		Options.Clock(func() time.Time { return clock }),
+		Options.Credentials(NewStaticProvider(testCredSuiteV4)),
+		Options.Region("us-east-1"),
+		Options.Service("service"),
+		Options.Logger(this.logger),
+	)
+}
+
+// The public key of the credentials of the Signature Version 4a test suite.
+// https://github.com/awslabs/aws-c-auth/tree/main/tests/aws-signing-test-suite/v4a
+func (this *Sign4aFixture) publicKey() *ecdsa.PublicKey {
+	x, _ := new(big.Int).SetString("b6618f6a65740a99e650b33b6b4b5bd0d43b176d721a3edfea7e7d2d56d936b1", 16)
+	y, _ := new(big.Int).SetString("865ed22a7eadc9c5cb9d2cbaca1b3699139fedc5043dc6661864218330c8e518", 16)
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+}
+
+func (this *Sign4aFixture) assertSigned(stringToSign, signature string) {
+	digest := sha256.Sum256([]byte(stringToSign))
+	decoded, err := hex.DecodeString(signature)
+	this.So(err, should.BeNil)
+	this.So(ecdsa.VerifyASN1(this.publicKey(), digest[:], decoded), should.BeTrue)
+}
+
+func (this *Sign4aFixture) TestKeyDerivation() {
+	key, err := deriveKeyV4a(testCredSuiteV4)
+	this.So(err, should.BeNil)
+	this.So(key.PublicKey.Equal(this.publicKey()), should.BeTrue)
+
+	// From the tests of the AWS SDK for Go v2.
+	key, err = deriveKeyV4a(Credentials{AccessKeyID: "AKISORANDOMAASORANDOM", SecretAccessKey: "q+jcrXGc+0zWN6uzclKVhvMmUsIfRPa4rlRandom"})
+	this.So(err, should.BeNil)
+	this.So(strings.ToUpper(key.X.Text(16)), should.Equal, "15D242CEEBF8D8169FD6A8B5A746C41140414C3B07579038DA06AF89190FFFCB")
+	this.So(strings.ToUpper(key.Y.Text(16)), should.Equal, "515242CEDD82E94799482E4C0514B505AFCCF2C0C98D6A553BF539F424C5EC0")
+}
+
+func (this *Sign4aFixture) TestPresignedGetVanilla() {
+	request, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+
+	err := this.signer.Presign4a(request, time.Hour)
+
+	this.So(err, should.BeNil)
+	canonicalRequest := "GET\n/\n" +
+		"X-Amz-Algorithm=AWS4-ECDSA-P256-SHA256&X-Amz-Credential=AKIDEXAMPLE%2F20150830%2Fservice%2Faws4_request" +
+		"&X-Amz-Date=20150830T123600Z&X-Amz-Expires=3600&X-Amz-Region-Set=us-east-1&X-Amz-SignedHeaders=host\n" +
+		"host:example.amazonaws.com\n\n" +
+		"host\n" +
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	stringToSign := "AWS4-ECDSA-P256-SHA256\n20150830T123600Z\n20150830/service/aws4_request\n" + hashSHA256([]byte(canonicalRequest))
+	this.So(this.logger.String(), should.Equal,
+		"awsauth: canonical request:\n"+canonicalRequest+"\n"+
+			"awsauth: string to sign:\n"+stringToSign)
+
+	query := request.URL.Query()
+	this.So(query.Get("X-Amz-Region-Set"), should.Equal, "us-east-1")
+	this.So(request.URL.RawQuery, should.StartWith, strings.Split(canonicalRequest, "\n")[2]+"&X-Amz-Signature=")
+	this.assertSigned(stringToSign, query.Get("X-Amz-Signature"))
+}
+
+func (this *Sign4aFixture) TestSignedHeaders() {
+	request, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+
+	err := this.signer.Sign4a(request)
+
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("X-Amz-Region-Set"), should.Equal, "us-east-1")
+	this.So(this.logger.String(), should.ContainSubstring, "\nx-amz-date:20150830T123600Z\nx-amz-region-set:us-east-1\n\n")
+
+	const prefix = "AWS4-ECDSA-P256-SHA256 Credential=AKIDEXAMPLE/20150830/service/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date;x-amz-region-set, Signature="
+	authorization := request.Header.Get("Authorization")
+	this.So(authorization, should.StartWith, prefix)
+
+	logged := this.logger.String()
+	stringToSign := logged[strings.Index(logged, "AWS4-ECDSA-P256-SHA256\n"):]
+	this.So(stringToSign, should.StartWith, "AWS4-ECDSA-P256-SHA256\n20150830T123600Z\n20150830/service/aws4_request\n")
+	this.assertSigned(stringToSign, strings.TrimPrefix(authorization, prefix))
+}
+
+func (this *Sign4aFixture) TestRegionSet() {
+	configured, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	NewSigner(Options.Credentials(NewStaticProvider(testCredSuiteV4)), Options.RegionSet("us-east-1", "us-west-2")).Sign4a(configured)
+
+	given, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	given.Header.Set("X-Amz-Region-Set", "*")
+	this.signer.Sign4a(given)
+
+	this.So(configured.Header.Get("X-Amz-Region-Set"), should.Equal, "us-east-1,us-west-2")
+	this.So(given.Header.Get("X-Amz-Region-Set"), should.Equal, "*")
+}
+
+func (this *Sign4aFixture) TestSecurityToken() {
+	request, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+
+	_, err := Sign4aE(request, Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SecurityToken: "token"})
+
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("X-Amz-Security-Token"), should.Equal, "token")
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "x-amz-security-token")
+}
+
+func (this *Sign4aFixture) TestPresignExpiration() {
+	request, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+
+	err := this.signer.Presign4a(request, 8*24*time.Hour)
+
+	this.So(err, should.Equal, errPresignExpiresV4)
+}
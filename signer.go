@@ -0,0 +1,316 @@
+package awsauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"time"
+)
+
+// Signer signs requests according to its own configuration rather than the
+// package defaults, so that different parts of a program can sign requests
+// differently. Create one with NewSigner and the functions of Options.
+// A Signer is safe for concurrent use.
+type Signer struct {
+	clock       func() time.Time
+	credentials CredentialsProvider
+	region      string
+	regionSet   []string
+	service     string
+	signed      map[string]bool
+	unsigned    map[string]bool
+	payload     PayloadMode
+	logger      Logger
+	versions    map[string]int
+	maxSkew     time.Duration
+}
+
+// NewSigner creates a Signer configured by the given options.
+func NewSigner(options ...Option) *Signer {
+	this := &Signer{}
+	for _, option := range options {
+		option(this)
+	}
+	return this
+}
+
+// Sign signs the request with the scheme of the service it is bound for,
+// like SignE, using credentials from the Signer's provider.
+func (this *Signer) Sign(request *http.Request) error {
+	keys, err := this.keys(request.Context())
+	if err != nil {
+		return err
+	}
+	_, err = this.sign(request, keys)
+	return err
+}
+
+// Presign adds the credentials and a Version 4 signature to the query string
+// of the request, like Presign4E, using credentials from the Signer's provider.
+func (this *Signer) Presign(request *http.Request, expires time.Duration) error {
+	keys, err := this.keys(request.Context())
+	if err != nil {
+		return err
+	}
+	return this.presignV4(request, expires, keys)
+}
+
+// Sign4a signs the request with Signature Version 4a, like Sign4aE, using
+// credentials from the Signer's provider.
+func (this *Signer) Sign4a(request *http.Request) error {
+	keys, err := this.keys(request.Context())
+	if err != nil {
+		return err
+	}
+	return this.sign4a(request, keys)
+}
+
+// Presign4a adds the credentials, the region set and a Version 4a signature
+// to the query string of the request, like Presign4aE, using credentials from
+// the Signer's provider.
+func (this *Signer) Presign4a(request *http.Request, expires time.Duration) error {
+	keys, err := this.keys(request.Context())
+	if err != nil {
+		return err
+	}
+	return this.presignV4a(request, expires, keys)
+}
+
+// SignS3 signs the request with the custom authentication scheme of Amazon S3,
+// like SignS3E, using credentials from the Signer's provider.
+func (this *Signer) SignS3(request *http.Request) error {
+	keys, err := this.keys(request.Context())
+	if err != nil {
+		return err
+	}
+	return this.signS3(request, keys)
+}
+
+func (this *Signer) keys(ctx context.Context) (Credentials, error) {
+	provider := this.credentials
+	if provider == nil {
+		provider = defaultProvider
+	}
+	credentials, err := provider.Retrieve(ctx)
+	if errors.Is(err, ErrNoCredentials) {
+		return Credentials{}, err
+	}
+	if err != nil {
+		return Credentials{}, fmt.Errorf("%w: %v", ErrNoCredentials, err)
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return Credentials{}, ErrNoCredentials
+	}
+	return credentials, nil
+}
+
+// now returns the current time according to the Signer's clock.
+func (this *Signer) now() time.Time {
+	if this.clock != nil {
+		return this.clock().UTC()
+	}
+	return now()
+}
+
+// serviceAndRegion returns the service and region a request to the host is
+// signed for. Those given with WithService and WithRegion in the request
+// context, or else in the Signer's options, are used as they are; the host
+// is only parsed for what wasn't given.
+func (this *Signer) serviceAndRegion(request *http.Request, host string) (service string, region string) {
+	service, region = this.explicitScope(request)
+	if service != "" && region != "" {
+		return service, region
+	}
+
+	parsedService, parsedRegion := serviceAndRegion(host)
+	if service == "" {
+		service = parsedService
+	}
+	if region == "" {
+		region = parsedRegion
+	}
+	return service, region
+}
+
+// explicitScope returns the service and region given for the request in its
+// context or the Signer's options, if any.
+func (this *Signer) explicitScope(request *http.Request) (service string, region string) {
+	service, region = this.service, this.region
+	if value, ok := request.Context().Value(serviceKey{}).(string); ok && value != "" {
+		service = value
+	}
+	if value, ok := request.Context().Value(regionKey{}).(string); ok && value != "" {
+		region = value
+	}
+	return service, region
+}
+
+// signVersion returns the signing scheme of the service, or 0 if it is
+// not known. Services given explicitly for the request are signed with
+// Version 4 unless the Signer is configured otherwise.
+func (this *Signer) signVersion(request *http.Request, service string) int {
+	if version, found := this.versions[service]; found {
+		return version
+	}
+	if version, found := awsSignVersion[service]; found {
+		return version
+	}
+	if explicit, _ := this.explicitScope(request); explicit != "" && explicit == service {
+		return 4
+	}
+	return 0
+}
+
+func (this *Signer) logf(format string, args ...interface{}) {
+	if this.logger != nil {
+		this.logger.Printf(format, args...)
+	}
+}
+
+// WithRegion returns a copy of the context that makes Version 4 signing of a
+// request carrying it use the given region instead of the one in the hostname,
+// for endpoints whose hostname doesn't name the region, such as LocalStack,
+// MinIO, VPC endpoints or custom domains.
+func WithRegion(ctx context.Context, region string) context.Context {
+	return context.WithValue(ctx, regionKey{}, region)
+}
+
+// WithService returns a copy of the context that makes signing of a request
+// carrying it use the given signing name of a service (such as "execute-api")
+// instead of the one in the hostname. Sign uses Version 4 for services the
+// library doesn't know.
+func WithService(ctx context.Context, service string) context.Context {
+	return context.WithValue(ctx, serviceKey{}, service)
+}
+
+type (
+	regionKey  struct{}
+	serviceKey struct{}
+)
+
+// PayloadMode determines how the body of a request is represented in a
+// Version 4 signature.
+type PayloadMode int
+
+const (
+	// SignedPayload includes the SHA-256 hash of the body in the signature.
+	SignedPayload PayloadMode = iota
+
+	// UnsignedPayload leaves the body out of the signature by signing with
+	// UNSIGNED-PAYLOAD, which S3 accepts. The body is never read.
+	UnsignedPayload
+)
+
+// Logger receives the canonical request and string to sign of each
+// Version 4 signature, which helps in finding out why AWS rejects one,
+// and the reason an Authenticator rejected a request.
+// A *log.Logger is a Logger.
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// Option configures a Signer.
+type Option func(*Signer)
+
+// Options holds the functions that create the options of NewSigner:
+//
+//	signer := awsauth.NewSigner(
+//		awsauth.Options.Credentials(provider),
+//		awsauth.Options.Region("eu-west-1"),
+//	)
+var Options options
+
+type options struct{}
+
+// Clock sets the function the Signer gets the current time from.
+// Defaults to the system clock.
+func (options) Clock(clock func() time.Time) Option {
+	return func(this *Signer) { this.clock = clock }
+}
+
+// Credentials sets the provider of the credentials to sign with.
+// Defaults to the provider chain used by the signing functions.
+func (options) Credentials(provider CredentialsProvider) Option {
+	return func(this *Signer) { this.credentials = provider }
+}
+
+// Region sets the region requests are signed for, instead of the one
+// in the hostname of each request.
+func (options) Region(region string) Option {
+	return func(this *Signer) { this.region = region }
+}
+
+// RegionSet sets the regions a Version 4a signature is valid in, such as
+// "*" for all of them. Defaults to the region of each request. A region set
+// already in the X-Amz-Region-Set header of a request takes precedence.
+func (options) RegionSet(regions ...string) Option {
+	return func(this *Signer) { this.regionSet = append([]string(nil), regions...) }
+}
+
+// Service sets the signing name of the service requests are signed for,
+// instead of the one in the hostname of each request. Sign uses Version 4
+// for services the library doesn't know.
+func (options) Service(service string) Option {
+	return func(this *Signer) { this.service = service }
+}
+
+// SignHeaders adds headers to those included in a Version 4 signature,
+// which are otherwise Host, Content-Type, Content-Md5 and X-Amz-*, such as
+// Range, If-Match, Content-Length or headers of your own. Headers that are
+// changed on the way to AWS, like User-Agent, Expect and the hop-by-hop
+// headers, are never signed.
+func (options) SignHeaders(names ...string) Option {
+	return func(this *Signer) { this.signed = addHeaderNames(this.signed, names) }
+}
+
+// IgnoreHeaders keeps headers out of a Version 4 signature, for example
+// those a proxy is known to change on the way to AWS. The Host header
+// is always signed.
+func (options) IgnoreHeaders(names ...string) Option {
+	return func(this *Signer) { this.unsigned = addHeaderNames(this.unsigned, names) }
+}
+
+// Payload sets how request bodies are represented in a Version 4 signature.
+// A hash in the request context or the X-Amz-Content-Sha256 header takes
+// precedence. Defaults to SignedPayload.
+func (options) Payload(mode PayloadMode) Option {
+	return func(this *Signer) { this.payload = mode }
+}
+
+// Logger sets the Logger to debug signatures with. Defaults to none.
+func (options) Logger(logger Logger) Option {
+	return func(this *Signer) { this.logger = logger }
+}
+
+// SignVersion sets the signing scheme Sign uses for the service: 2, 3 or 4
+// for the respective signature versions, or -1 for the custom scheme of S3.
+func (options) SignVersion(service string, version int) Option {
+	return func(this *Signer) {
+		if this.versions == nil {
+			this.versions = make(map[string]int)
+		}
+		this.versions[service] = version
+	}
+}
+
+// MaxSkew sets how far the time a request was signed at may be from the
+// Signer's clock for the request to pass verification. Defaults to 15 minutes,
+// like AWS.
+func (options) MaxSkew(skew time.Duration) Option {
+	return func(this *Signer) { this.maxSkew = skew }
+}
+
+func addHeaderNames(set map[string]bool, names []string) map[string]bool {
+	if set == nil {
+		set = make(map[string]bool)
+	}
+	for _, name := range names {
+		set[textproto.CanonicalMIMEHeaderKey(name)] = true
+	}
+	return set
+}
+
+// defaultSigner signs requests for the package-level signing functions.
+var defaultSigner = NewSigner()
@@ -0,0 +1,245 @@
+package awsauth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/assertions/should"
+	"github.com/smartystreets/gunit"
+)
+
+func TestSignerFixture(t *testing.T) {
+	gunit.Run(new(SignerFixture), t)
+}
+
+type SignerFixture struct {
+	*gunit.Fixture
+
+	clock    time.Time
+	provider *fakeProvider
+}
+
+func (this *SignerFixture) Setup() {
+	this.clock, _ = time.Parse(timeFormatV4, "20110909T233600Z")
+	this.provider = &fakeProvider{credentials: *testCredV4}
+}
+
+func (this *SignerFixture) newSigner(options ...Option) *Signer {
+	defaults := []Option{
+		Options.Clock(func() time.Time { return this.clock }),
+		Options.Credentials(this.provider),
+	}
+	return NewSigner(append(defaults, options...)...)
+}
+
+func (this *SignerFixture) TestSignUsesClockAndCredentialsProvider() {
+	request := test_plainRequestV4(false)
+
+	err := this.newSigner().Sign(request)
+
+	this.So(err, should.BeNil)
+	this.So(this.provider.calls, should.Equal, 1)
+	this.So(request.Header.Get("X-Amz-Date"), should.Equal, "20110909T233600Z")
+	this.So(request.Header.Get("Authorization"), should.StartWith,
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20110909/us-east-1/iam/aws4_request, ")
+}
+
+func (this *SignerFixture) TestSignersWithDifferentClocksDoNotInterfere() {
+	var waiter sync.WaitGroup
+	dates := make([]string, 10)
+	for i := range dates {
+		waiter.Add(1)
+		go func(i int) {
+			defer waiter.Done()
+			clock := time.Date(2011, 9, i+1, 0, 0, 0, 0, time.UTC)
+			signer := NewSigner(
+				Options.Clock(func() time.Time { return clock }),
+				Options.Credentials(NewStaticProvider(*testCredV4)),
+			)
+			request := test_plainRequestV4(false)
+			signer.Sign(request)
+			dates[i] = request.Header.Get("X-Amz-Date")
+		}(i)
+	}
+	waiter.Wait()
+
+	for i, date := range dates {
+		this.So(date, should.Equal, fmt.Sprintf("201109%02dT000000Z", i+1))
+	}
+}
+
+func (this *SignerFixture) TestCredentialsFailure() {
+	this.provider.err = errors.New("no credentials")
+	request := test_plainRequestV4(false)
+
+	err := this.newSigner().Sign(request)
+
+	this.So(errors.Is(err, ErrNoCredentials), should.BeTrue)
+	this.So(request.Header.Get("Authorization"), should.BeBlank)
+}
+
+func (this *SignerFixture) TestChainFailureIsNotWrappedTwice() {
+	chain := NewChainProvider(&fakeProvider{err: errors.New("nope")})
+
+	err := NewSigner(Options.Credentials(chain)).Sign(test_plainRequestV4(false))
+
+	this.So(err.Error(), should.Equal, "awsauth: no credentials available: *awsauth.fakeProvider: nope")
+}
+
+func (this *SignerFixture) TestRegionAndServiceOverride() {
+	request, _ := http.NewRequest("GET", "https://localhost:4566/", nil)
+
+	err := this.newSigner(Options.Region("eu-west-1"), Options.Service("execute-api")).Sign(request)
+
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/20110909/eu-west-1/execute-api/aws4_request, ")
+}
+
+func (this *SignerFixture) TestRegionAndServiceFromContextTakePrecedence() {
+	request, _ := http.NewRequest("GET", "http://localhost:9000/bucket/object", nil)
+	ctx := WithRegion(request.Context(), "ap-southeast-2")
+	request = request.WithContext(WithService(ctx, "s3"))
+
+	err := this.newSigner(Options.Region("eu-west-1"), Options.Service("execute-api")).Sign(request)
+
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/20110909/ap-southeast-2/s3/aws4_request, ")
+}
+
+func (this *SignerFixture) TestRegionFromContextKeepsServiceFromHost() {
+	request, _ := http.NewRequest("GET", "https://iam.amazonaws.com/", nil)
+	request = request.WithContext(WithRegion(request.Context(), "us-gov-west-1"))
+
+	this.newSigner().Sign(request)
+
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/20110909/us-gov-west-1/iam/aws4_request, ")
+}
+
+func (this *SignerFixture) TestUnknownServiceFromContextIsSignedWithVersion4() {
+	request, _ := http.NewRequest("GET", "https://api.example.com/prod/pets", nil)
+	ctx := WithRegion(request.Context(), "us-west-2")
+	request = request.WithContext(WithService(ctx, "execute-api"))
+
+	_, err := SignE(request, *testCredV4)
+
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring, "/us-west-2/execute-api/aws4_request, ")
+}
+
+func (this *SignerFixture) TestUnknownServiceIsNotSigned() {
+	request, _ := http.NewRequest("GET", "https://unknown.example.com/", nil)
+
+	err := this.newSigner().Sign(request)
+
+	this.So(errors.Is(err, ErrUnknownService), should.BeTrue)
+}
+
+func (this *SignerFixture) TestSignVersionOverride() {
+	request, _ := http.NewRequest("GET", "https://iam.amazonaws.com/?Action=ListUsers", nil)
+
+	this.newSigner(Options.SignVersion("iam", 2)).Sign(request)
+
+	this.So(request.URL.Query().Get("SignatureVersion"), should.Equal, "2")
+	this.So(request.URL.Query().Get("Timestamp"), should.Equal, "2011-09-09T23:36:00")
+	this.So(request.Header.Get("Authorization"), should.BeBlank)
+}
+
+func (this *SignerFixture) TestSignedAndIgnoredHeaders() {
+	request := test_plainRequestV4(false)
+	request.Header.Set("X-Custom", "value")
+	request.Header.Set("X-Amz-Meta-Proxied", "value")
+
+	this.newSigner(
+		Options.SignHeaders("x-custom"),
+		Options.IgnoreHeaders("X-AMZ-META-PROXIED", "Content-Type", "Host"),
+	).Sign(request)
+
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring,
+		"SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-custom, ")
+}
+
+func (this *SignerFixture) TestAdditionalSignedHeaders() {
+	request, _ := http.NewRequest("PUT", "https://examplebucket.s3.amazonaws.com/object", strings.NewReader("content"))
+	request.Header.Set("Range", "bytes=0-9")
+	request.Header.Set("User-Agent", "client/1.0")
+	request.Header.Set("Expect", "100-continue")
+
+	this.newSigner(Options.SignHeaders("Content-Length", "Range", "User-Agent", "Expect")).Sign(request)
+
+	this.So(request.Header.Get("Content-Length"), should.Equal, "7")
+	this.So(request.Header.Get("Authorization"), should.ContainSubstring,
+		"SignedHeaders=content-length;content-type;host;range;x-amz-content-sha256;x-amz-date, ")
+}
+
+func (this *SignerFixture) TestUnsignedPayload() {
+	request, _ := http.NewRequest("PUT", "https://examplebucket.s3.amazonaws.com/large", test_unreadableBody{})
+
+	err := this.newSigner(Options.Payload(UnsignedPayload)).Sign(request)
+
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("X-Amz-Content-Sha256"), should.Equal, "UNSIGNED-PAYLOAD")
+}
+
+func (this *SignerFixture) TestPrecomputedHashTakesPrecedenceOverUnsignedPayload() {
+	precomputed := hashSHA256([]byte("payload"))
+	request, _ := http.NewRequest("PUT", "https://examplebucket.s3.amazonaws.com/object", test_unreadableBody{})
+	request = request.WithContext(WithPayloadHash(request.Context(), precomputed))
+
+	this.newSigner(Options.Payload(UnsignedPayload)).Sign(request)
+
+	this.So(request.Header.Get("X-Amz-Content-Sha256"), should.Equal, precomputed)
+}
+
+func (this *SignerFixture) TestLogger() {
+	logger := &fakeLogger{}
+	request := test_plainRequestV4(false)
+
+	this.newSigner(Options.Logger(logger)).Sign(request)
+
+	this.So(logger.String(), should.ContainSubstring, "awsauth: canonical request:\nPOST\n/\n\n")
+	this.So(logger.String(), should.ContainSubstring, "awsauth: string to sign:\nAWS4-HMAC-SHA256\n20110909T233600Z\n")
+}
+
+func (this *SignerFixture) TestPresign() {
+	request, _ := http.NewRequest("GET", "https://examplebucket.s3.amazonaws.com/test.txt", nil)
+
+	err := this.newSigner().Presign(request, time.Hour)
+
+	this.So(err, should.BeNil)
+	this.So(request.URL.Query().Get("X-Amz-Date"), should.Equal, "20110909T233600Z")
+	this.So(request.URL.Query().Get("X-Amz-Credential"), should.Equal, "AKIDEXAMPLE/20110909/us-east-1/s3/aws4_request")
+	this.So(request.URL.Query().Get("X-Amz-Signature"), should.NotBeBlank)
+}
+
+func (this *SignerFixture) TestSignS3() {
+	request, _ := http.NewRequest("GET", "https://johnsmith.s3.amazonaws.com/photos/puppy.jpg", nil)
+
+	err := this.newSigner().SignS3(request)
+
+	this.So(err, should.BeNil)
+	this.So(request.Header.Get("Date"), should.Equal, "Fri, 09 Sep 2011 23:36:00 +0000")
+	this.So(request.Header.Get("Authorization"), should.StartWith, "AWS AKIDEXAMPLE:")
+}
+
+type fakeLogger struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (this *fakeLogger) Printf(format string, args ...interface{}) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	fmt.Fprintf(&this.buffer, format+"\n", args...)
+}
+
+func (this *fakeLogger) String() string {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return strings.TrimSpace(this.buffer.String())
+}
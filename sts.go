@@ -0,0 +1,370 @@
+package awsauth
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AssumeRoleProvider obtains temporary credentials for an IAM role by calling
+// the STS AssumeRole action, signed with the credentials of another provider.
+// The temporary credentials are reused until they are about to expire.
+type AssumeRoleProvider struct {
+	// Source supplies the credentials used to sign the AssumeRole request.
+	Source CredentialsProvider
+
+	// RoleARN is the Amazon Resource Name of the role to assume.
+	RoleARN string
+
+	// RoleSessionName identifies the session in CloudTrail.
+	// Defaults to a name derived from the current time.
+	RoleSessionName string
+
+	// ExternalID is passed along when the role's trust policy requires one.
+	ExternalID string
+
+	// Duration is the requested lifetime of the credentials. When zero,
+	// STS applies its default of one hour.
+	Duration time.Duration
+
+	// Tags are passed as session tags.
+	Tags map[string]string
+
+	// SerialNumber is the ARN or serial number of the MFA device
+	// required by the role, if any.
+	SerialNumber string
+
+	// TokenCode is called on every refresh to obtain the current MFA code
+	// when SerialNumber is set.
+	TokenCode func() (string, error)
+
+	// Endpoint is the URL of the STS service. Defaults to https://sts.amazonaws.com.
+	Endpoint string
+
+	// Client performs the STS requests. Defaults to a client with a
+	// timeout of half a minute.
+	Client *http.Client
+
+	mutex       sync.Mutex
+	credentials Credentials
+}
+
+// Retrieve returns the current temporary credentials, assuming the role
+// again if they are missing or within the expiration window.
+func (this *AssumeRoleProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.credentials.AccessKeyID != "" && !this.credentials.expired() {
+		return this.credentials, nil
+	}
+	this.credentials = Credentials{}
+
+	if this.Source == nil {
+		return Credentials{}, errNoSourceProvider
+	}
+	source, err := this.Source.Retrieve(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	values, err := this.parameters()
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	request, err := newSTSRequest(ctx, this.Endpoint, values)
+	if err != nil {
+		return Credentials{}, err
+	}
+	if _, err := Sign4E(request, source); err != nil {
+		return Credentials{}, err
+	}
+
+	response := assumeRoleResponse{}
+	if err := doSTSRequest(this.Client, request, &response); err != nil {
+		return Credentials{}, err
+	}
+
+	credentials, err := response.Result.Credentials.toCredentials()
+	if err != nil {
+		return Credentials{}, err
+	}
+	this.credentials = credentials
+	return credentials, nil
+}
+
+// IsExpired reports whether the temporary credentials are missing or within
+// the expiration window.
+func (this *AssumeRoleProvider) IsExpired() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.credentials.AccessKeyID == "" || this.credentials.expired()
+}
+
+func (this *AssumeRoleProvider) parameters() (url.Values, error) {
+	values := url.Values{}
+	values.Set("Action", "AssumeRole")
+	values.Set("Version", stsVersion)
+	values.Set("RoleArn", this.RoleARN)
+	values.Set("RoleSessionName", sessionName(this.RoleSessionName))
+
+	if this.Duration > 0 {
+		values.Set("DurationSeconds", strconv.Itoa(int(this.Duration/time.Second)))
+	}
+	if this.ExternalID != "" {
+		values.Set("ExternalId", this.ExternalID)
+	}
+
+	keys := make([]string, 0, len(this.Tags))
+	for key := range this.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		prefix := "Tags.member." + strconv.Itoa(i+1)
+		values.Set(prefix+".Key", key)
+		values.Set(prefix+".Value", this.Tags[key])
+	}
+
+	if this.SerialNumber != "" {
+		if this.TokenCode == nil {
+			return nil, errNoTokenCode
+		}
+		code, err := this.TokenCode()
+		if err != nil {
+			return nil, err
+		}
+		values.Set("SerialNumber", this.SerialNumber)
+		values.Set("TokenCode", code)
+	}
+
+	return values, nil
+}
+
+// WebIdentityProvider obtains temporary credentials for an IAM role by
+// exchanging an OpenID Connect token, such as the service account token that
+// EKS projects into pods, with the unsigned STS AssumeRoleWithWebIdentity
+// action. The token file is read again on every refresh.
+type WebIdentityProvider struct {
+	// RoleARN is the Amazon Resource Name of the role to assume.
+	// Defaults to the value of AWS_ROLE_ARN.
+	RoleARN string
+
+	// TokenFile is the path of the file holding the web identity token.
+	// Defaults to the value of AWS_WEB_IDENTITY_TOKEN_FILE.
+	TokenFile string
+
+	// RoleSessionName identifies the session in CloudTrail. Defaults to the
+	// value of AWS_ROLE_SESSION_NAME or a name derived from the current time.
+	RoleSessionName string
+
+	// Duration is the requested lifetime of the credentials. When zero,
+	// STS applies its default of one hour.
+	Duration time.Duration
+
+	// Endpoint is the URL of the STS service. Defaults to https://sts.amazonaws.com.
+	Endpoint string
+
+	// Client performs the STS requests. Defaults to a client with a
+	// timeout of half a minute.
+	Client *http.Client
+
+	mutex       sync.Mutex
+	credentials Credentials
+}
+
+// Retrieve returns the current temporary credentials, exchanging the web
+// identity token again if they are missing or within the expiration window.
+func (this *WebIdentityProvider) Retrieve(ctx context.Context) (Credentials, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.credentials.AccessKeyID != "" && !this.credentials.expired() {
+		return this.credentials, nil
+	}
+	this.credentials = Credentials{}
+
+	roleARN, tokenFile := this.roleARN(), this.tokenFile()
+	if roleARN == "" || tokenFile == "" {
+		return Credentials{}, errWebIdentityNotConfigured
+	}
+
+	token, err := ioutil.ReadFile(tokenFile)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	values := url.Values{}
+	values.Set("Action", "AssumeRoleWithWebIdentity")
+	values.Set("Version", stsVersion)
+	values.Set("RoleArn", roleARN)
+	values.Set("RoleSessionName", sessionName(this.roleSessionName()))
+	values.Set("WebIdentityToken", strings.TrimSpace(string(token)))
+	if this.Duration > 0 {
+		values.Set("DurationSeconds", strconv.Itoa(int(this.Duration/time.Second)))
+	}
+
+	request, err := newSTSRequest(ctx, this.Endpoint, values)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	response := assumeRoleWithWebIdentityResponse{}
+	if err := doSTSRequest(this.Client, request, &response); err != nil {
+		return Credentials{}, err
+	}
+
+	credentials, err := response.Result.Credentials.toCredentials()
+	if err != nil {
+		return Credentials{}, err
+	}
+	this.credentials = credentials
+	return credentials, nil
+}
+
+// IsExpired reports whether the temporary credentials are missing or within
+// the expiration window.
+func (this *WebIdentityProvider) IsExpired() bool {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.credentials.AccessKeyID == "" || this.credentials.expired()
+}
+
+func (this *WebIdentityProvider) roleARN() string {
+	if this.RoleARN != "" {
+		return this.RoleARN
+	}
+	return firstEnv(envRoleARN)
+}
+
+func (this *WebIdentityProvider) tokenFile() string {
+	if this.TokenFile != "" {
+		return this.TokenFile
+	}
+	return firstEnv(envWebIdentityTokenFile)
+}
+
+func (this *WebIdentityProvider) roleSessionName() string {
+	if this.RoleSessionName != "" {
+		return this.RoleSessionName
+	}
+	return firstEnv(envRoleSessionName)
+}
+
+type assumeRoleResponse struct {
+	Result struct {
+		Credentials stsCredentials
+	} `xml:"AssumeRoleResult"`
+}
+
+type assumeRoleWithWebIdentityResponse struct {
+	Result struct {
+		Credentials stsCredentials
+	} `xml:"AssumeRoleWithWebIdentityResult"`
+}
+
+type stsCredentials struct {
+	AccessKeyID     string    `xml:"AccessKeyId"`
+	SecretAccessKey string    `xml:"SecretAccessKey"`
+	SessionToken    string    `xml:"SessionToken"`
+	Expiration      time.Time `xml:"Expiration"`
+}
+
+func (this stsCredentials) toCredentials() (Credentials, error) {
+	if this.AccessKeyID == "" || this.SecretAccessKey == "" {
+		return Credentials{}, errSTSCredentialsNotFound
+	}
+	return Credentials{
+		AccessKeyID:     this.AccessKeyID,
+		SecretAccessKey: this.SecretAccessKey,
+		SecurityToken:   this.SessionToken,
+		Expiration:      this.Expiration,
+	}, nil
+}
+
+type stsErrorResponse struct {
+	Error struct {
+		Code    string
+		Message string
+	}
+}
+
+func newSTSRequest(ctx context.Context, endpoint string, values url.Values) (*http.Request, error) {
+	if endpoint == "" {
+		endpoint = stsEndpoint
+	}
+
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	return request.WithContext(ctx), nil
+}
+
+// doSTSRequest sends the request and decodes a successful XML response into
+// result. Error responses are reported with the code and message from STS.
+func doSTSRequest(client *http.Client, request *http.Request, result interface{}) error {
+	if client == nil {
+		client = stsClient
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		failure := stsErrorResponse{}
+		if xml.Unmarshal(body, &failure) != nil || failure.Error.Code == "" {
+			return fmt.Errorf("awsauth: sts request returned %s", response.Status)
+		}
+		return fmt.Errorf("awsauth: sts request returned %s: %s: %s", response.Status, failure.Error.Code, failure.Error.Message)
+	}
+
+	return xml.Unmarshal(body, result)
+}
+
+func sessionName(name string) string {
+	if name != "" {
+		return name
+	}
+	return "awsauth-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+const (
+	stsEndpoint = "https://sts.amazonaws.com"
+	stsVersion  = "2011-06-15"
+
+	envRoleARN              = "AWS_ROLE_ARN"
+	envWebIdentityTokenFile = "AWS_WEB_IDENTITY_TOKEN_FILE"
+	envRoleSessionName      = "AWS_ROLE_SESSION_NAME"
+)
+
+var (
+	stsClient = &http.Client{Timeout: 30 * time.Second}
+
+	errNoSourceProvider = errors.New("awsauth: assume role requires a source credentials provider")
+	errNoTokenCode      = errors.New("awsauth: assume role with an MFA serial number requires a TokenCode callback")
+
+	errWebIdentityNotConfigured = errors.New("awsauth: web identity role ARN and token file not configured")
+	errSTSCredentialsNotFound   = errors.New("awsauth: sts response did not include credentials")
+)
@@ -0,0 +1,73 @@
+package awsauth
+
+import "net/http"
+
+// Transport is an http.RoundTripper that signs each request before passing
+// it on to another RoundTripper. Requests are cloned before they are signed,
+// so the caller's request is left as it was (apart from its body, which is
+// consumed as usual). Use it as the Transport of an http.Client to have every
+// request the client makes signed:
+//
+//	client := &http.Client{Transport: &awsauth.Transport{}}
+type Transport struct {
+	// Base performs the signed requests. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+
+	// Credentials supplies the credentials for signing. Defaults to the
+	// provider chain used by the signing functions.
+	Credentials CredentialsProvider
+
+	// Sign signs the cloned request. Defaults to SignE, which chooses the
+	// signing scheme based on the service the request is bound for.
+	Sign func(request *http.Request, credentials ...Credentials) (*http.Request, error)
+}
+
+// RoundTrip signs a clone of the request and sends it with the base transport.
+func (this *Transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	keys, err := this.credentials().Retrieve(request.Context())
+	if err != nil {
+		closeBody(request)
+		return nil, err
+	}
+
+	clone := request.Clone(request.Context())
+	signed, err := this.sign()(clone, keys)
+	if clone.Body != request.Body {
+		// Signing replaced the body with a buffered copy; the transport
+		// will close that one, so close the caller's body here.
+		closeBody(request)
+	}
+	if err != nil {
+		closeBody(clone)
+		return nil, err
+	}
+
+	return this.base().RoundTrip(signed)
+}
+
+func (this *Transport) base() http.RoundTripper {
+	if this.Base != nil {
+		return this.Base
+	}
+	return http.DefaultTransport
+}
+
+func (this *Transport) credentials() CredentialsProvider {
+	if this.Credentials != nil {
+		return this.Credentials
+	}
+	return defaultProvider
+}
+
+func (this *Transport) sign() func(*http.Request, ...Credentials) (*http.Request, error) {
+	if this.Sign != nil {
+		return this.Sign
+	}
+	return SignE
+}
+
+func closeBody(request *http.Request) {
+	if request.Body != nil {
+		request.Body.Close()
+	}
+}
@@ -0,0 +1,121 @@
+package awsauth
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/smartystreets/assertions/should"
+	"github.com/smartystreets/gunit"
+)
+
+func TestTransportFixture(t *testing.T) {
+	gunit.Run(new(TransportFixture), t)
+}
+
+type TransportFixture struct {
+	*gunit.Fixture
+
+	base      *fakeRoundTripper
+	transport *Transport
+}
+
+func (this *TransportFixture) Setup() {
+	this.base = &fakeRoundTripper{}
+	this.transport = &Transport{
+		Base:        this.base,
+		Credentials: NewStaticProvider(*testCredV4),
+	}
+}
+
+func (this *TransportFixture) TestRequestIsSignedAndSent() {
+	request, _ := http.NewRequest("POST", "https://iam.amazonaws.com/", strings.NewReader("Action=ListUsers"))
+
+	response, err := this.transport.RoundTrip(request)
+
+	this.So(err, should.BeNil)
+	this.So(response.StatusCode, should.Equal, http.StatusOK)
+	this.So(this.base.request.Header.Get("Authorization"), should.StartWith, "AWS4-HMAC-SHA256 Credential="+testCredV4.AccessKeyID)
+	this.So(this.base.body, should.Equal, "Action=ListUsers")
+}
+
+func (this *TransportFixture) TestCallersRequestIsNotMutated() {
+	request, _ := http.NewRequest("GET", "https://iam.amazonaws.com/?Action=ListUsers", nil)
+
+	this.transport.RoundTrip(request)
+
+	this.So(this.base.request, should.NotPointTo, request)
+	this.So(request.Header, should.BeEmpty)
+	this.So(request.URL.String(), should.Equal, "https://iam.amazonaws.com/?Action=ListUsers")
+}
+
+func (this *TransportFixture) TestSigningSchemeCanBeChosen() {
+	this.transport.Sign = Sign2E
+	request, _ := http.NewRequest("GET", "https://sdb.amazonaws.com/?Action=ListDomains", nil)
+
+	this.transport.RoundTrip(request)
+
+	this.So(this.base.request.URL.Query().Get("Signature"), should.NotBeBlank)
+	this.So(request.URL.Query().Get("Signature"), should.BeBlank)
+}
+
+func (this *TransportFixture) TestCredentialsFailure() {
+	this.transport.Credentials = &fakeProvider{err: errors.New("no credentials")}
+	request, _ := http.NewRequest("GET", "https://iam.amazonaws.com/", nil)
+
+	response, err := this.transport.RoundTrip(request)
+
+	this.So(response, should.BeNil)
+	this.So(err, should.NotBeNil)
+	this.So(this.base.request, should.BeNil)
+}
+
+func (this *TransportFixture) TestSigningFailure() {
+	request, _ := http.NewRequest("GET", "https://unknown.example.com/", nil)
+
+	response, err := this.transport.RoundTrip(request)
+
+	this.So(response, should.BeNil)
+	this.So(errors.Is(err, ErrUnknownService), should.BeTrue)
+	this.So(this.base.request, should.BeNil)
+}
+
+func (this *TransportFixture) TestWithHTTPClient() {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		authorization = request.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	this.transport.Base = nil
+	this.transport.Sign = Sign4E
+	client := &http.Client{Transport: this.transport}
+
+	response, err := client.Get(server.URL)
+
+	this.So(err, should.BeNil)
+	this.So(response.StatusCode, should.Equal, http.StatusOK)
+	this.So(authorization, should.StartWith, "AWS4-HMAC-SHA256")
+}
+
+type fakeRoundTripper struct {
+	request *http.Request
+	body    string
+}
+
+func (this *fakeRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	this.request = request
+	if request.Body != nil {
+		body, _ := ioutil.ReadAll(request.Body)
+		request.Body.Close()
+		this.body = string(body)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+		Request:    request,
+	}, nil
+}
@@ -0,0 +1,188 @@
+package awsauth
+
+import (
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SecretLookup returns the secret access key that belongs to an access key
+// ID, so that the signature of a request made with it can be verified.
+// It returns an error for access keys it doesn't know.
+type SecretLookup func(accessKeyID string) (secretAccessKey string, err error)
+
+// Verify checks the signature of a request received by a server with the
+// scheme it was signed with: Signature Version 4 in the Authorization header
+// or the query string, Signature Version 2 or the custom scheme of S3 in
+// either place. It returns the access key ID the request was signed with.
+func Verify(request *http.Request, lookupSecret SecretLookup) (string, error) {
+	return defaultSigner.Verify(request, lookupSecret)
+}
+
+// Verify is like the package-level Verify, but takes the time from the
+// Signer's clock and allows for the Signer's maximum skew.
+func (this *Signer) Verify(request *http.Request, lookupSecret SecretLookup) (string, error) {
+	authorization := request.Header.Get("Authorization")
+	query := request.URL.Query()
+
+	switch {
+	case strings.HasPrefix(authorization, "AWS4-"):
+		return this.verify4(request, lookupSecret)
+	case strings.HasPrefix(authorization, "AWS "):
+		return this.verifyS3(request, lookupSecret)
+	case authorization != "":
+		return "", ErrMalformedSignature
+	case query.Get("X-Amz-Algorithm") != "" || query.Get("X-Amz-Signature") != "":
+		return this.verify4(request, lookupSecret)
+	case query.Get("SignatureVersion") != "":
+		return this.verify2(request, lookupSecret)
+	case isFormV2(request):
+		return this.verify2(request, lookupSecret)
+	case query.Get("Signature") != "" && query.Get("Expires") != "":
+		return this.verifyS3Url(request, lookupSecret)
+	}
+	return "", ErrNotSigned
+}
+
+// Verify4 checks the Signed Signature Version 4 of a request received by
+// a server, whether it is in the Authorization header or the query string of
+// a presigned URL, and returns the access key ID it was signed with. The time
+// the request was signed at must be within 15 minutes of now and a presigned
+// request must not have expired. The region and service are taken from the
+// signature's credential scope, which must name those a Signer was given
+// with its options or WithRegion and WithService, if any.
+//
+// Requests sent in aws-chunked encoding are verified up to the seed signature;
+// the signatures of the chunks are not checked.
+func Verify4(request *http.Request, lookupSecret SecretLookup) (string, error) {
+	return defaultSigner.Verify4(request, lookupSecret)
+}
+
+// Verify4 is like the package-level Verify4, but takes the time from the
+// Signer's clock and allows for the Signer's maximum skew.
+func (this *Signer) Verify4(request *http.Request, lookupSecret SecretLookup) (string, error) {
+	return this.verify4(request, lookupSecret)
+}
+
+// Verify2 checks the Signed Signature Version 2 in the query string of a
+// request received by a server, or in the body of a form POST, and returns
+// the access key ID it was signed with. Signatures made with HmacSHA256 and
+// HmacSHA1 are accepted. The request must carry a Timestamp within 15 minutes
+// of now or an Expires time that hasn't passed.
+func Verify2(request *http.Request, lookupSecret SecretLookup) (string, error) {
+	return defaultSigner.Verify2(request, lookupSecret)
+}
+
+// Verify2 is like the package-level Verify2, but takes the time from the
+// Signer's clock and allows for the Signer's maximum skew.
+func (this *Signer) Verify2(request *http.Request, lookupSecret SecretLookup) (string, error) {
+	return this.verify2(request, lookupSecret)
+}
+
+// VerifyS3 checks the signature of a request received by a server that was
+// signed with the custom authentication scheme of Amazon S3 (as SignS3 does),
+// in an Authorization header of the form "AWS AccessKeyId:Signature", and
+// returns the access key ID it was signed with. The Date or X-Amz-Date header
+// must be within 15 minutes of now.
+func VerifyS3(request *http.Request, lookupSecret SecretLookup) (string, error) {
+	return defaultSigner.VerifyS3(request, lookupSecret)
+}
+
+// VerifyS3 is like the package-level VerifyS3, but takes the time from the
+// Signer's clock and allows for the Signer's maximum skew.
+func (this *Signer) VerifyS3(request *http.Request, lookupSecret SecretLookup) (string, error) {
+	return this.verifyS3(request, lookupSecret)
+}
+
+// VerifyS3Url checks the signature of a presigned S3 URL (as made by SignS3Url)
+// received by a server, which must not have expired, and returns the access
+// key ID it was signed with.
+func VerifyS3Url(request *http.Request, lookupSecret SecretLookup) (string, error) {
+	return defaultSigner.VerifyS3Url(request, lookupSecret)
+}
+
+// VerifyS3Url is like the package-level VerifyS3Url, but takes the time from
+// the Signer's clock.
+func (this *Signer) VerifyS3Url(request *http.Request, lookupSecret SecretLookup) (string, error) {
+	return this.verifyS3Url(request, lookupSecret)
+}
+
+// secretOf finds the secret of the access key with the lookup function.
+func secretOf(lookup SecretLookup, accessKeyID string) (string, error) {
+	if accessKeyID == "" {
+		return "", ErrMalformedSignature
+	}
+	secret, err := lookup(accessKeyID)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnknownAccessKey, err)
+	}
+	if secret == "" {
+		return "", ErrUnknownAccessKey
+	}
+	return secret, nil
+}
+
+// compareSignatures reports whether the signatures are equal in a time
+// that doesn't depend on how much of them is.
+func compareSignatures(expected, actual string) error {
+	if !hmac.Equal([]byte(expected), []byte(actual)) {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
+
+// checkSkew makes sure the signing time is close enough to now.
+func (this *Signer) checkSkew(signed time.Time) error {
+	skew := this.now().Sub(signed)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > this.skew() {
+		return ErrRequestTimeSkewed
+	}
+	return nil
+}
+
+func (this *Signer) skew() time.Duration {
+	if this.maxSkew > 0 {
+		return this.maxSkew
+	}
+	return defaultMaxSkew
+}
+
+const defaultMaxSkew = 15 * time.Minute
+
+var (
+	// ErrNotSigned is returned when verifying a request that carries no signature.
+	ErrNotSigned = errors.New("awsauth: request is not signed")
+
+	// ErrMalformedSignature is returned when verifying a request whose
+	// signature or the parameters that go with it can't be understood.
+	ErrMalformedSignature = errors.New("awsauth: malformed signature")
+
+	// ErrUnknownAccessKey is returned when verifying a request signed with
+	// an access key the SecretLookup doesn't know.
+	ErrUnknownAccessKey = errors.New("awsauth: unknown access key")
+
+	// ErrSignatureMismatch is returned when the signature of a request
+	// isn't the one calculated for it.
+	ErrSignatureMismatch = errors.New("awsauth: signature does not match")
+
+	// ErrPayloadHashMismatch is returned when the X-Amz-Content-Sha256 header
+	// of a request doesn't match its body.
+	ErrPayloadHashMismatch = errors.New("awsauth: payload hash does not match the body")
+
+	// ErrContentMD5Mismatch is returned when the Content-MD5 header of a
+	// request doesn't match its body.
+	ErrContentMD5Mismatch = errors.New("awsauth: content MD5 does not match the body")
+
+	// ErrRequestTimeSkewed is returned when the time a request was signed at
+	// is too far from the current time.
+	ErrRequestTimeSkewed = errors.New("awsauth: request time is too skewed")
+
+	// ErrRequestExpired is returned when a presigned request has expired.
+	ErrRequestExpired = errors.New("awsauth: request has expired")
+)
@@ -0,0 +1,190 @@
+package awsauth
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smartystreets/assertions/should"
+	"github.com/smartystreets/gunit"
+)
+
+func TestVerify2Fixture(t *testing.T) {
+	gunit.Run(new(Verify2Fixture), t)
+}
+
+type Verify2Fixture struct {
+	*gunit.Fixture
+
+	clock  time.Time
+	signer *Signer
+}
+
+func (this *Verify2Fixture) Setup() {
+	this.clock, _ = time.Parse(timeFormatV2, exampleReqTsV2)
+	this.signer = this.signerAt(0)
+}
+
+func (this *Verify2Fixture) signerAt(offset time.Duration) *Signer {
+	return NewSigner(Options.Clock(func() time.Time { return this.clock.Add(offset) }))
+}
+
+func (this *Verify2Fixture) lookup(accessKeyID string) (string, error) {
+	if accessKeyID != testCredV2.AccessKeyID {
+		return "", errors.New("no such key")
+	}
+	return testCredV2.SecretAccessKey, nil
+}
+
+func (this *Verify2Fixture) TestDocumentationExample() {
+	request, _ := http.NewRequest("GET", expectedFinalUrlV2, nil)
+
+	accessKeyID, err := this.signer.Verify2(request, this.lookup)
+
+	this.So(err, should.BeNil)
+	this.So(accessKeyID, should.Equal, testCredV2.AccessKeyID)
+}
+
+func (this *Verify2Fixture) TestRepeatedParameters() {
+	request, _ := http.NewRequest("GET", "https://sdb.amazonaws.com/?Action=PutAttributes&Attribute.Value=b&Attribute.Value=a%20c", nil)
+	this.signer.sign2(request, *testCredV2)
+
+	_, err := this.signer.Verify2(request, this.lookup)
+
+	this.So(request.URL.Query()["Attribute.Value"], should.Resemble, []string{"a c", "b"})
+	this.So(request.URL.RawQuery, should.ContainSubstring, "&Attribute.Value=a%20c&Attribute.Value=b&")
+	this.So(err, should.BeNil)
+}
+
+func (this *Verify2Fixture) TestSignedRequestReceivedByServer() {
+	var accessKeyID string
+	var err error
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		accessKeyID, err = this.signer.Verify2(request, this.lookup)
+	}))
+	defer server.Close()
+
+	request, _ := http.NewRequest("GET", server.URL+"?Action=ListDomains&Version=2009-04-15", nil)
+	this.signer.sign2(request, *testCredV2)
+	http.DefaultClient.Do(request)
+
+	this.So(err, should.BeNil)
+	this.So(accessKeyID, should.Equal, testCredV2.AccessKeyID)
+}
+
+func (this *Verify2Fixture) TestSignedFormReceivedByServer() {
+	var accessKeyID string
+	var err error
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		accessKeyID, err = this.signer.Verify(request, this.lookup)
+		body, _ = ioutil.ReadAll(request.Body)
+	}))
+	defer server.Close()
+
+	request, _ := http.NewRequest("POST", server.URL, strings.NewReader("Action=SendMessage&MessageBody=a%20b"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	this.signer.sign2(request, *testCredV2)
+	http.DefaultClient.Do(request)
+
+	this.So(err, should.BeNil)
+	this.So(accessKeyID, should.Equal, testCredV2.AccessKeyID)
+	this.So(string(body), should.ContainSubstring, "&MessageBody=a%20b&")
+}
+
+func (this *Verify2Fixture) TestTamperedForm() {
+	request, _ := http.NewRequest("POST", "https://sqs.us-east-1.amazonaws.com/", strings.NewReader("Action=SendMessage"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	this.signer.sign2(request, *testCredV2)
+	body, _ := ioutil.ReadAll(request.Body)
+	request.Body = ioutil.NopCloser(strings.NewReader(strings.Replace(string(body), "SendMessage", "DeleteQueue", 1)))
+
+	_, err := this.signer.Verify2(request, this.lookup)
+
+	this.So(err, should.Equal, ErrSignatureMismatch)
+}
+
+func (this *Verify2Fixture) TestQueryParameterAddedToForm() {
+	request, _ := http.NewRequest("POST", "https://sdb.amazonaws.com/", strings.NewReader("Action=ListDomains"))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	this.signer.sign2(request, *testCredV2)
+	request.URL.RawQuery = "Action=DeleteDomain"
+
+	_, err := this.signer.Verify(request, this.lookup)
+
+	this.So(err, should.Equal, ErrSignatureMismatch)
+}
+
+func (this *Verify2Fixture) TestHmacSHA1() {
+	query := url.Values{
+		"Action":           {"ListDomains"},
+		"AWSAccessKeyId":   {testCredV2.AccessKeyID},
+		"SignatureMethod":  {"HmacSHA1"},
+		"SignatureVersion": {"2"},
+		"Timestamp":        {"2011-10-03T15:19:30Z"},
+	}
+	stringToSign := "GET\nsdb.amazonaws.com\n/\n" + query.Encode()
+	query.Set("Signature", base64.StdEncoding.EncodeToString(hmacSHA1([]byte(testCredV2.SecretAccessKey), stringToSign)))
+	request, _ := http.NewRequest("GET", "https://sdb.amazonaws.com/?"+query.Encode(), nil)
+
+	_, err := this.signer.Verify2(request, this.lookup)
+
+	this.So(err, should.BeNil)
+}
+
+func (this *Verify2Fixture) TestTamperedRequest() {
+	request, _ := http.NewRequest("GET", expectedFinalUrlV2+"&Extra=1", nil)
+
+	_, err := this.signer.Verify2(request, this.lookup)
+
+	this.So(err, should.Equal, ErrSignatureMismatch)
+}
+
+func (this *Verify2Fixture) TestRequestTimeSkewed() {
+	request, _ := http.NewRequest("GET", expectedFinalUrlV2, nil)
+
+	_, err := this.signerAt(16*time.Minute).Verify2(request, this.lookup)
+
+	this.So(err, should.Equal, ErrRequestTimeSkewed)
+}
+
+func (this *Verify2Fixture) TestExpiredRequest() {
+	request, _ := http.NewRequest("GET", "https://sdb.amazonaws.com/?Action=ListDomains&Expires=2011-10-03T15:30:00Z", nil)
+	this.signer.sign2(request, *testCredV2)
+
+	_, valid := this.signer.Verify2(request, this.lookup)
+	_, expired := this.signerAt(time.Hour).Verify2(request, this.lookup)
+
+	this.So(valid, should.BeNil)
+	this.So(expired, should.Equal, ErrRequestExpired)
+}
+
+func (this *Verify2Fixture) TestUnknownAccessKey() {
+	request, _ := http.NewRequest("GET", "https://sdb.amazonaws.com/?Action=ListDomains", nil)
+	this.signer.sign2(request, Credentials{AccessKeyID: "AKIDUNKNOWN", SecretAccessKey: "secret"})
+
+	_, err := this.signer.Verify2(request, this.lookup)
+
+	this.So(errors.Is(err, ErrUnknownAccessKey), should.BeTrue)
+}
+
+func (this *Verify2Fixture) TestNotSignedOrMalformed() {
+	request, _ := http.NewRequest("GET", "https://sdb.amazonaws.com/?Action=ListDomains", nil)
+	_, notSigned := this.signer.Verify2(request, this.lookup)
+
+	request, _ = http.NewRequest("GET", "https://sdb.amazonaws.com/?Action=ListDomains&Signature=abc&SignatureVersion=1", nil)
+	_, wrongVersion := this.signer.Verify2(request, this.lookup)
+
+	request, _ = http.NewRequest("GET", "https://sdb.amazonaws.com/?Action=ListDomains&Signature=abc&SignatureVersion=2&Timestamp=yesterday", nil)
+	_, badTimestamp := this.signer.Verify2(request, this.lookup)
+
+	this.So(notSigned, should.Equal, ErrNotSigned)
+	this.So(wrongVersion, should.Equal, ErrMalformedSignature)
+	this.So(badTimestamp, should.Equal, ErrMalformedSignature)
+}
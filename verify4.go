@@ -0,0 +1,348 @@
+package awsauth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// authorizationV4 holds what a Version 4 signature says about the request
+// it was sent with, whether in the Authorization header or the query string.
+type authorizationV4 struct {
+	accessKeyID   string
+	date          string
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+	timestamp     string
+	signedAt      time.Time
+	expires       time.Duration
+	query         url.Values
+}
+
+func (this *Signer) verify4(request *http.Request, lookup SecretLookup) (string, error) {
+	auth, err := parseSignatureV4(request)
+	if err != nil {
+		return "", err
+	}
+	if err := this.checkScopeV4(request, auth); err != nil {
+		return "", err
+	}
+	if err := this.checkTimeV4(auth); err != nil {
+		return "", err
+	}
+
+	secret, err := secretOf(lookup, auth.accessKeyID)
+	if err != nil {
+		return "", err
+	}
+
+	payloadHash, err := signedPayloadHashV4(request, auth)
+	if err != nil {
+		return "", err
+	}
+
+	// The server keeps the Host and Content-Length headers out of the header
+	// map; put them back so the canonical request has them as they were signed.
+	clone := request.Clone(request.Context())
+	clone.Header.Set("Host", request.Host)
+	if clone.Header.Get("Content-Length") == "" && request.ContentLength >= 0 {
+		clone.Header.Set("Content-Length", strconv.FormatInt(request.ContentLength, 10))
+	}
+	if clone.URL.Path == "" {
+		clone.URL.Path = "/"
+	}
+
+	canonicalRequest := canonicalRequestV4(clone, auth.service, auth.query, auth.signedHeaders, payloadHash)
+	this.logf("awsauth: canonical request:\n%s", canonicalRequest)
+	scope := concat("/", auth.date, auth.region, auth.service, "aws4_request")
+	stringToSign := concat("\n", "AWS4-HMAC-SHA256", auth.timestamp, scope, hashSHA256([]byte(canonicalRequest)))
+	this.logf("awsauth: string to sign:\n%s", stringToSign)
+
+	signingKey := signingKeyV4(secret, auth.date, auth.region, auth.service)
+	if err := compareSignatures(signatureV4(signingKey, stringToSign), auth.signature); err != nil {
+		return "", err
+	}
+
+	// Only now that the client is known to hold the secret is the body it
+	// claims to have hashed read in full.
+	if err := checkPayloadHashV4(request, payloadHash); err != nil {
+		return "", err
+	}
+	return auth.accessKeyID, nil
+}
+
+// checkScopeV4 makes sure a request was signed for the service and region
+// the Signer was given, in its options or the request context, if any.
+func (this *Signer) checkScopeV4(request *http.Request, auth *authorizationV4) error {
+	service, region := this.explicitScope(request)
+	if service != "" && service != auth.service {
+		return ErrMalformedSignature
+	}
+	if region != "" && region != auth.region {
+		return ErrMalformedSignature
+	}
+	return nil
+}
+
+// checkTimeV4 makes sure a request was signed recently enough or, if it was
+// presigned, has not expired.
+func (this *Signer) checkTimeV4(auth *authorizationV4) error {
+	if auth.expires == 0 {
+		return this.checkSkew(auth.signedAt)
+	}
+	if auth.signedAt.Sub(this.now()) > this.skew() {
+		return ErrRequestTimeSkewed
+	}
+	if this.now().After(auth.signedAt.Add(auth.expires)) {
+		return ErrRequestExpired
+	}
+	return nil
+}
+
+// signedPayloadHashV4 returns the payload hash the request was signed with:
+// the one in the X-Amz-Content-Sha256 header, which is checked against the
+// body once the signature is, or else the hash of the body, of which no more
+// than maxBufferedPayloadV4 bytes are read.
+func signedPayloadHashV4(request *http.Request, auth *authorizationV4) (string, error) {
+	if auth.expires > 0 && auth.service == "s3" {
+		// Presigned requests to S3 are always signed without their payload.
+		return unsignedPayloadV4, nil
+	}
+	if claimed := request.Header.Get("X-Amz-Content-Sha256"); claimed != "" {
+		return claimed, nil
+	}
+
+	if rewindable(request) {
+		h := sha256.New()
+		n, err := hashBody(request, h)
+		if err != nil {
+			return "", err
+		}
+		if n > maxBufferedPayloadV4 {
+			return "", errPayloadTooLargeV4
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	payload, err := bufferBodyV4(request)
+	if err != nil {
+		return "", err
+	}
+	if int64(len(payload)) > maxBufferedPayloadV4 {
+		return "", errPayloadTooLargeV4
+	}
+	return hashSHA256(payload), nil
+}
+
+// checkPayloadHashV4 makes sure the hash in the X-Amz-Content-Sha256 header
+// of the request is the hash of its body. The body is streamed through the
+// hash if it can be rewound, and buffered if it is small enough; otherwise
+// a mismatch is reported by the body itself, when it has been read to the end.
+func checkPayloadHashV4(request *http.Request, payloadHash string) error {
+	if payloadHash == unsignedPayloadV4 || strings.HasPrefix(payloadHash, "STREAMING-") {
+		return nil
+	}
+	if request.Header.Get("X-Amz-Content-Sha256") == "" {
+		return nil // the hash was taken from the body
+	}
+
+	h := sha256.New()
+	if rewindable(request) {
+		if _, err := hashBody(request, h); err != nil {
+			return err
+		}
+	} else {
+		payload, err := bufferBodyV4(request)
+		if err != nil {
+			return err
+		}
+		h.Write(payload)
+		if int64(len(payload)) > maxBufferedPayloadV4 {
+			body := request.Body.(*bufferedBodyV4)
+			request.Body = &checkedBodyV4{
+				Reader:      io.MultiReader(bytes.NewReader(payload), io.TeeReader(body.rest, h)),
+				Closer:      body,
+				hash:        h,
+				payloadHash: payloadHash,
+			}
+			return nil
+		}
+	}
+
+	if !strings.EqualFold(payloadHash, hex.EncodeToString(h.Sum(nil))) {
+		return ErrPayloadHashMismatch
+	}
+	return nil
+}
+
+// rewindable reports whether the body of the request can be read again
+// without being buffered.
+func rewindable(request *http.Request) bool {
+	_, seekable := request.Body.(io.Seeker)
+	return request.Body == nil || request.Body == http.NoBody || seekable || request.GetBody != nil
+}
+
+// bufferBodyV4 reads up to one byte more than maxBufferedPayloadV4 of the
+// body of the request and puts what it read back in front of the rest.
+func bufferBodyV4(request *http.Request) ([]byte, error) {
+	rest := request.Body
+	payload, err := ioutil.ReadAll(io.LimitReader(rest, maxBufferedPayloadV4+1))
+	request.Body = &bufferedBodyV4{Reader: io.MultiReader(bytes.NewReader(payload), rest), rest: rest}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBodyRead, err)
+	}
+	return payload, nil
+}
+
+type bufferedBodyV4 struct {
+	io.Reader
+	rest io.ReadCloser
+}
+
+func (this *bufferedBodyV4) Close() error { return this.rest.Close() }
+
+// checkedBodyV4 hashes the rest of a body that was too large to buffer while
+// it is read, and fails the read that reaches its end if the hash is not the
+// one it was signed with.
+type checkedBodyV4 struct {
+	io.Reader
+	io.Closer
+	hash        hash.Hash
+	payloadHash string
+}
+
+func (this *checkedBodyV4) Read(p []byte) (int, error) {
+	n, err := this.Reader.Read(p)
+	if err == io.EOF && !strings.EqualFold(this.payloadHash, hex.EncodeToString(this.hash.Sum(nil))) {
+		return n, ErrPayloadHashMismatch
+	}
+	return n, err
+}
+
+// maxBufferedPayloadV4 is the most of a body that can't be rewound that is
+// held in memory: that of a body whose hash isn't in the X-Amz-Content-Sha256
+// header, which must be read in full before the signature is checked, may be
+// no larger; a larger one whose hash is given is checked as it is read.
+const maxBufferedPayloadV4 = 8 << 20
+
+var errPayloadTooLargeV4 = errors.New("awsauth: body too large to verify without an X-Amz-Content-Sha256 header")
+
+// parseSignatureV4 reads the signature from the Authorization header or,
+// failing that, the query string. The signature must cover the Host header,
+// so that it isn't valid for any other host, and the X-Amz-Date header if
+// the request has one, so that the time checked is the time signed.
+func parseSignatureV4(request *http.Request) (*authorizationV4, error) {
+	var auth *authorizationV4
+	var err error
+
+	query := parseQuery(request.URL.RawQuery)
+	if header := request.Header.Get("Authorization"); header != "" {
+		auth, err = parseAuthorizationV4(header, request.Header.Get("X-Amz-Date"), query)
+	} else if query.Get("X-Amz-Signature") != "" || query.Get("X-Amz-Algorithm") != "" {
+		auth, err = parsePresignedV4(query)
+	} else {
+		return nil, ErrNotSigned
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !auth.signs("host") {
+		return nil, ErrMalformedSignature
+	}
+	if request.Header.Get("X-Amz-Date") != "" && !auth.signs("x-amz-date") {
+		return nil, ErrMalformedSignature
+	}
+	return auth, nil
+}
+
+// parseAuthorizationV4 reads a header of the form
+// AWS4-HMAC-SHA256 Credential=.../aws4_request, SignedHeaders=..., Signature=...
+func parseAuthorizationV4(header, timestamp string, query url.Values) (*authorizationV4, error) {
+	const prefix = "AWS4-HMAC-SHA256 "
+	if !strings.HasPrefix(header, prefix) {
+		return nil, ErrMalformedSignature
+	}
+
+	fields := map[string]string{}
+	for _, field := range strings.Split(strings.TrimPrefix(header, prefix), ",") {
+		if pair := strings.SplitN(strings.TrimSpace(field), "=", 2); len(pair) == 2 {
+			fields[pair[0]] = pair[1]
+		}
+	}
+
+	auth := &authorizationV4{
+		signature: fields["Signature"],
+		timestamp: timestamp,
+		query:     query,
+	}
+	return auth, auth.parse(fields["Credential"], fields["SignedHeaders"])
+}
+
+// parsePresignedV4 reads the X-Amz-* parameters of a presigned URL.
+func parsePresignedV4(query url.Values) (*authorizationV4, error) {
+	if query.Get("X-Amz-Algorithm") != "AWS4-HMAC-SHA256" {
+		return nil, ErrMalformedSignature
+	}
+	expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil || expires < 1 || time.Duration(expires)*time.Second > maxExpiresV4 {
+		return nil, ErrMalformedSignature
+	}
+
+	auth := &authorizationV4{
+		signature: query.Get("X-Amz-Signature"),
+		timestamp: query.Get("X-Amz-Date"),
+		expires:   time.Duration(expires) * time.Second,
+		query:     url.Values{},
+	}
+	for key, values := range query {
+		if key != "X-Amz-Signature" {
+			auth.query[key] = values
+		}
+	}
+	return auth, auth.parse(query.Get("X-Amz-Credential"), query.Get("X-Amz-SignedHeaders"))
+}
+
+// parse fills in the fields found in the credential and signed headers,
+// and checks that the signature is complete.
+func (this *authorizationV4) parse(credential, signedHeaders string) error {
+	scope := strings.Split(credential, "/")
+	if len(scope) != 5 || scope[4] != "aws4_request" {
+		return ErrMalformedSignature
+	}
+	this.accessKeyID, this.date, this.region, this.service = scope[0], scope[1], scope[2], scope[3]
+
+	signedAt, err := time.Parse(timeFormatV4, this.timestamp)
+	if err != nil || tsDateV4(this.timestamp) != this.date {
+		return ErrMalformedSignature
+	}
+	this.signedAt = signedAt
+
+	if signedHeaders == "" || this.signature == "" {
+		return ErrMalformedSignature
+	}
+	this.signedHeaders = strings.Split(signedHeaders, ";")
+	return nil
+}
+
+// signs reports whether the header is among those signed.
+func (this *authorizationV4) signs(header string) bool {
+	for _, signed := range this.signedHeaders {
+		if signed == header {
+			return true
+		}
+	}
+	return false
+}
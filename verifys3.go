@@ -0,0 +1,129 @@
+package awsauth
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (this *Signer) verifyS3(request *http.Request, lookup SecretLookup) (string, error) {
+	header := request.Header.Get("Authorization")
+	if header == "" {
+		return "", ErrNotSigned
+	}
+	credential := strings.SplitN(strings.TrimPrefix(header, "AWS "), ":", 2)
+	if !strings.HasPrefix(header, "AWS ") || len(credential) != 2 || credential[1] == "" {
+		return "", ErrMalformedSignature
+	}
+	accessKeyID, signature := credential[0], credential[1]
+
+	signedAt, err := parseTimeS3(request)
+	if err != nil {
+		return "", ErrMalformedSignature
+	}
+	if err := this.checkSkew(signedAt); err != nil {
+		return "", err
+	}
+
+	secret, err := secretOf(lookup, accessKeyID)
+	if err != nil {
+		return "", err
+	}
+	keys := Credentials{SecretAccessKey: secret}
+
+	clone := request.Clone(request.Context())
+	if clone.URL.Path == "" {
+		clone.URL.Path = "/"
+	}
+	matches := func(contentMD5 string) bool {
+		expected := signatureS3(this.stringToSignS3WithMD5(clone, contentMD5), keys)
+		return compareSignatures(expected, signature) == nil
+	}
+
+	// Clients sign the Content-MD5 header they send, which must then be the
+	// MD5 of the body. Those of this library also sign the MD5 of a body they
+	// send without one.
+	contentMD5 := request.Header.Get("Content-Md5")
+	if contentMD5 != "" {
+		if !matches(contentMD5) {
+			return "", ErrSignatureMismatch
+		}
+		actual, _, err := bodyMD5(request)
+		if err != nil {
+			return "", err
+		}
+		if actual != contentMD5 {
+			return "", ErrContentMD5Mismatch
+		}
+		return accessKeyID, nil
+	}
+
+	if matches("") {
+		return accessKeyID, nil
+	}
+	actual, n, err := bodyMD5(request)
+	if err != nil {
+		return "", err
+	}
+	if n > 0 && matches(actual) {
+		return accessKeyID, nil
+	}
+	return "", ErrSignatureMismatch
+}
+
+// bodyMD5 returns the base64-encoded MD5 of the request body and its length,
+// leaving the body ready to be read again.
+func bodyMD5(request *http.Request) (string, int64, error) {
+	h := md5.New()
+	n, err := hashBody(request, h)
+	if err != nil {
+		return "", 0, err
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), n, nil
+}
+
+func (this *Signer) verifyS3Url(request *http.Request, lookup SecretLookup) (string, error) {
+	query := request.URL.Query()
+	signature := query.Get("Signature")
+	if signature == "" {
+		return "", ErrNotSigned
+	}
+	seconds, err := strconv.ParseInt(query.Get("Expires"), 10, 64)
+	if err != nil {
+		return "", ErrMalformedSignature
+	}
+	expire := time.Unix(seconds, 0)
+	if this.now().After(expire) {
+		return "", ErrRequestExpired
+	}
+
+	accessKeyID := query.Get("AWSAccessKeyId")
+	secret, err := secretOf(lookup, accessKeyID)
+	if err != nil {
+		return "", err
+	}
+
+	stringToSign := stringToSignS3Url(request.Method, expire, request.URL.Path)
+	if err := compareSignatures(signatureS3(stringToSign, Credentials{SecretAccessKey: secret}), signature); err != nil {
+		return "", err
+	}
+	return accessKeyID, nil
+}
+
+// parseTimeS3 reads the time a request was signed at from its Date or
+// X-Amz-Date header.
+func parseTimeS3(request *http.Request) (time.Time, error) {
+	value := request.Header.Get("X-Amz-Date")
+	if value == "" {
+		value = request.Header.Get("Date")
+	}
+	for _, layout := range []string{timeFormatS3, time.RFC1123, timeFormatV4} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return http.ParseTime(value)
+}